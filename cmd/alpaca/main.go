@@ -1,21 +1,36 @@
 package main
 
 import (
-	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/bbogdan95/alpaca/pkg/engine"
+	"github.com/bbogdan95/alpaca/pkg/pgn"
 )
 
 func main() {
+	pgnFile := flag.String("pgn", "", "append the games played in console and xboard mode to this PGN file")
+	flag.Parse()
+
 	engine.InitAll()
 
 	board := &engine.Board{}
 	engine.InitHashTable(board, 4)
 
-	s := &engine.SearchInfo{}
+	// the protocol loops read the commands from the same input, so that no line is lost when switching to them
+	s := &engine.SearchInfo{Input: engine.NewInput(os.Stdin)}
+	if *pgnFile != "" {
+		s.GameOver = func(b *engine.Board, result string) {
+			if err := saveGame(*pgnFile, b, result); err != nil {
+				fmt.Println("Error saving game:", err)
+			}
+		}
+	}
 
 	fmt.Println(`
                        ∩~~∩ 
@@ -37,22 +52,26 @@ d88P     888 888 88888P"  "Y888888  "Y8888P "Y888888
                  888     `)
 	fmt.Printf("\nType 'console' for console mode...\n\n")
 
-	reader := bufio.NewReader(os.Stdin)
-
 	for {
-		line, err := reader.ReadString('\n')
+		line, err := s.Input.ReadLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
 			panic(err)
 		}
 
 		command := strings.TrimSpace(line)
 
-		if line == "\n" {
+		if command == "" {
 			continue
 		}
 
 		if command == "uci" {
 			err := engine.UCILoop(board, s)
+			if errors.Is(err, io.EOF) {
+				break
+			}
 			if err != nil {
 				panic(err)
 			}
@@ -65,6 +84,9 @@ d88P     888 888 88888P"  "Y888888  "Y8888P "Y888888
 
 		if command == "xboard" {
 			err := engine.XBoardLoop(board, s)
+			if errors.Is(err, io.EOF) {
+				break
+			}
 			if err != nil {
 				panic(err)
 			}
@@ -78,6 +100,9 @@ d88P     888 888 88888P"  "Y888888  "Y8888P "Y888888
 
 		if command == "console" {
 			err := engine.ConsoleLoop(board, s)
+			if errors.Is(err, io.EOF) {
+				break
+			}
 			if err != nil {
 				panic(err)
 			}
@@ -91,3 +116,20 @@ d88P     888 888 88888P"  "Y888888  "Y8888P "Y888888
 	}
 
 }
+
+// saveGame appends the game played on the board to a PGN file.
+func saveGame(path string, b *engine.Board, result string) error {
+	g := pgn.FromBoard(b)
+	g.SetTag("Event", "Alpaca game")
+	g.SetTag("Date", time.Now().Format("2006.01.02"))
+	g.SetTag("Result", result)
+	g.Result = result
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return pgn.NewWriter(file).Write(g)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+/*
+Searches every position of a suite to a fixed depth, with an empty hash table, and prints the number of nodes.
+The total is the same from one run to the next as long as the search does not change, which makes it a simple way
+to measure how much a change of the search saves (or costs), independently of the speed of the machine.
+*/
+
+func main() {
+	suite := flag.String("suite", "./bench.epd", "positions to search, one FEN per line (EPD operations after ; are ignored but for id)")
+	depth := flag.Int("depth", 7, "search depth")
+	hash := flag.Int("hash", 16, "hash table size in MB")
+	options := flag.String("options", "", "engine options, name=value separated by commas (LateMoveReductions=false...)")
+	flag.Parse()
+
+	file, err := os.Open(*suite)
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		os.Exit(-1)
+	}
+	defer file.Close()
+
+	e := engine.NewEngine()
+	if err := e.SetOption("Hash", strconv.Itoa(*hash)); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if err := e.SetOptions(*options); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	scanner := bufio.NewScanner(file)
+	nodes := uint64(0)
+	start := time.Now()
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		fen := strings.TrimSpace(parts[0])
+		name := fen
+		for _, op := range parts[1:] {
+			if id, ok := strings.CutPrefix(strings.TrimSpace(op), "id "); ok {
+				name = id
+			}
+		}
+
+		if err := e.SetPosition(fen); err != nil {
+			fmt.Printf("%s - %v\n", name, err)
+			os.Exit(-1)
+		}
+
+		e.NewGame()
+		r := e.Search(context.Background(), engine.Limits{Depth: *depth})
+		nodes += r.Nodes
+
+		fmt.Printf("%-16s %10d nodes  %-6s %6d\n", name, r.Nodes, engine.FormatSAN(r.BestMove, e.Board()), r.Score)
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Println("Error reading file:", err)
+		os.Exit(-1)
+	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("\n%d nodes in %v (%.0f nodes/s)\n", nodes, elapsed.Round(time.Millisecond), float64(nodes)/elapsed.Seconds())
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+func main() {
+	bookPath := flag.String("book", "", "polyglot book to probe")
+	fen := flag.String("fen", engine.START_FEN, "position to probe")
+	suite := flag.String("suite", "", "check the polyglot keys of the positions of this EPD suite (key opcode)")
+	flag.Parse()
+
+	engine.InitAll()
+
+	if *suite != "" {
+		PolyglotKeySuite(*suite)
+		return
+	}
+
+	if *bookPath == "" {
+		flag.Usage()
+		os.Exit(-1)
+	}
+
+	book, err := engine.LoadBook(*bookPath)
+	if err != nil {
+		fmt.Println("Error loading book:", err)
+		os.Exit(-1)
+	}
+
+	b := &engine.Board{}
+	if err := b.ParseFen(*fen); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	fmt.Printf("%d entries, key %016x\n", len(book.Entries), engine.PolyglotKey(b))
+
+	moves, weights := book.BookMoves(b)
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	for i, move := range moves {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(weights[i]) / float64(total)
+		}
+		fmt.Printf("%-8s %-6s weight %5d (%.1f%%)\n", engine.FormatSAN(move, b), engine.PrintMove(move), weights[i], share)
+	}
+}
+
+// PolyglotKeySuite checks the Polyglot keys of the positions of an EPD file, given as "fen ;key 463b96181691fc9c".
+func PolyglotKeySuite(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		os.Exit(-1)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	b := &engine.Board{}
+
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), ";")
+		if len(parts) < 2 {
+			continue
+		}
+
+		if err := b.ParseFen(parts[0]); err != nil {
+			fmt.Printf("%s\n - %v %s\n", parts[0], err, "❌")
+			os.Exit(-1)
+		}
+
+		fields := strings.Fields(parts[1])
+		if len(fields) != 2 || fields[0] != "key" {
+			fmt.Printf("%s\n - invalid key operation %q %s\n", parts[0], parts[1], "❌")
+			os.Exit(-1)
+		}
+		want, err := strconv.ParseUint(fields[1], 16, 64)
+		if err != nil {
+			fmt.Printf("%s\n - invalid key %q %s\n", parts[0], fields[1], "❌")
+			os.Exit(-1)
+		}
+
+		key := engine.PolyglotKey(b)
+		fmt.Printf("%s - %016x ", strings.TrimSpace(parts[0]), key)
+		if key != want {
+			fmt.Printf("%s\n", "❌")
+			os.Exit(-1)
+		}
+		fmt.Printf("%s\n", "✅")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+	"github.com/bbogdan95/alpaca/pkg/pgn"
+)
+
+// moveStats holds how often a move was played in a position, and how the games ended for the side that played it.
+type moveStats struct {
+	games  int
+	wins   int
+	draws  int
+	losses int
+}
+
+type bookKey struct {
+	key  uint64
+	move uint16
+}
+
+func main() {
+	out := flag.String("out", "book.bin", "polyglot book to write")
+	depth := flag.Int("depth", 20, "only add the moves of the first plies of every game")
+	minGames := flag.Int("min", 2, "only add the moves played in at least this many games")
+	winRate := flag.Bool("winrate", true, "weight the moves by their score (2 per win, 1 per draw) instead of how often they were played")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] games.pgn...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(-1)
+	}
+
+	engine.InitAll()
+
+	stats := map[bookKey]*moveStats{}
+	games := 0
+
+	for _, path := range flag.Args() {
+		n, err := addGames(path, stats, *depth)
+		games += n
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			os.Exit(-1)
+		}
+	}
+
+	book := buildBook(stats, *minGames, *winRate)
+
+	file, err := os.Create(*out)
+	if err != nil {
+		fmt.Println("Error creating book:", err)
+		os.Exit(-1)
+	}
+	defer file.Close()
+
+	if err := book.Write(file); err != nil {
+		fmt.Println("Error writing book:", err)
+		os.Exit(-1)
+	}
+
+	positions := 0
+	for i, e := range book.Entries {
+		if i == 0 || book.Entries[i-1].Key != e.Key {
+			positions++
+		}
+	}
+
+	fmt.Printf("%d games, %d positions, %d moves written to %s\n", games, positions, len(book.Entries), *out)
+}
+
+// addGames replays the main line of the games of a PGN file, adding the first depth plies to the statistics.
+// It returns the number of games read.
+func addGames(path string, stats map[bookKey]*moveStats, depth int) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	r := pgn.NewReader(file)
+	games := 0
+
+	for {
+		g, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return games, nil
+		}
+		if err != nil {
+			return games, err
+		}
+		games++
+
+		b, err := g.Board()
+		if err != nil {
+			return games, err
+		}
+
+		for ply, m := range g.Moves {
+			if ply >= depth {
+				break
+			}
+
+			k := bookKey{key: engine.PolyglotKey(b), move: engine.BookMove(m.Move)}
+			s := stats[k]
+			if s == nil {
+				s = &moveStats{}
+				stats[k] = s
+			}
+
+			s.games++
+			switch {
+			case g.Result == "1/2-1/2":
+				s.draws++
+			case (g.Result == "1-0") == (b.Side == engine.WHITE) && g.Result != "*":
+				s.wins++
+			case g.Result != "*":
+				s.losses++
+			}
+
+			if res, err := b.MakeMove(m.Move); err != nil || res == engine.FALSE {
+				return games, fmt.Errorf("game %d (%s - %s): illegal move %s", games, g.Tag("White"), g.Tag("Black"), m.SAN)
+			}
+			b.Ply = 0
+		}
+	}
+}
+
+// buildBook turns the statistics into book entries, dropping the moves played in less than minGames games.
+// The weights are scaled down if needed to fit the 16 bits of the Polyglot format. The moves left with a weight
+// of 0 (only lost with -winrate, or rounded down by the scaling) are dropped too, as PickMove would still play them
+// in a position where all the moves have a weight of 0.
+func buildBook(stats map[bookKey]*moveStats, minGames int, winRate bool) *engine.Book {
+	book := &engine.Book{}
+	keys := []bookKey{}
+	weights := []int{}
+
+	for k, s := range stats {
+		if s.games < minGames {
+			continue
+		}
+
+		weight := s.games
+		if winRate {
+			weight = 2*s.wins + s.draws
+		}
+
+		keys = append(keys, k)
+		weights = append(weights, weight)
+	}
+
+	maxWeight := 0
+	for _, w := range weights {
+		if w > maxWeight {
+			maxWeight = w
+		}
+	}
+
+	for i, w := range weights {
+		if maxWeight > 0xFFFF {
+			w = int(int64(w) * 0xFFFF / int64(maxWeight))
+		}
+		if w == 0 {
+			continue
+		}
+		book.Entries = append(book.Entries, engine.BookEntry{Key: keys[i].key, Move: keys[i].move, Weight: uint16(w)})
+	}
+
+	// sorted by key as required by the format, then by weight as done by the Polyglot tools
+	sort.Slice(book.Entries, func(i, j int) bool {
+		a, b := book.Entries[i], book.Entries[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.Move < b.Move
+	})
+
+	return book
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+/*
+Runs a suite of EPD test positions. Every line holds a FEN followed by operations separated by ";":
+
+	id name     -> name of the position
+	bm m1 m2... -> the best move must be one of these (SAN)
+	am m1 m2... -> the best move must not be one of these (SAN)
+	dm n        -> the side to move mates in n moves (n < 0: it is mated in -n moves), as reported in "score mate n"
+*/
+
+// position is a line of the suite.
+type position struct {
+	fen  string
+	name string
+	ops  map[string]string
+}
+
+// outcome is the report of the search of a position.
+type outcome struct {
+	line    string
+	failed  bool
+	timeout bool // The time limit stopped the search before the depth asked for.
+	nodes   uint64
+}
+
+func main() {
+	suite := flag.String("suite", "./matesuite.epd", "EPD suite to run")
+	depthList := flag.String("depth", "6,10", "search depths separated by commas, every position is searched to each of them")
+	movetime := flag.Int("time", 10000, "maximum search time per position in milliseconds")
+	hash := flag.Int("hash", 64, "hash table size in MB")
+	options := flag.String("options", "", "engine options, name=value separated by commas (NullMovePruning=false...)")
+	parallel := flag.Int("parallel", 1, "number of positions searched at the same time, each by its own engine")
+	flag.Parse()
+
+	positions, err := readSuite(*suite)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		os.Exit(-1)
+	}
+
+	// a shallow depth catches the prunings that hide mates until they are seen at a deeper one
+	var depths []int
+	for _, d := range strings.Split(*depthList, ",") {
+		depth, err := strconv.Atoi(strings.TrimSpace(d))
+		if err != nil || depth <= 0 {
+			fmt.Printf("invalid depth %q\n", d)
+			os.Exit(-1)
+		}
+		depths = append(depths, depth)
+	}
+
+	outcomes := make([]outcome, len(depths)*len(positions))
+	next := make(chan int)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < *parallel; i++ {
+		e := engine.NewEngine()
+		if err := e.SetOption("Hash", strconv.Itoa(*hash)); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+		if err := e.SetOptions(*options); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				limits := engine.Limits{Depth: depths[i/len(positions)], MoveTime: time.Duration(*movetime) * time.Millisecond}
+				outcomes[i] = run(e, positions[i%len(positions)], limits)
+			}
+		}()
+	}
+
+	for i := range outcomes {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed, timeouts, nodes := 0, 0, uint64(0)
+	for _, o := range outcomes {
+		fmt.Println(o.line)
+		nodes += o.nodes
+		if o.failed {
+			failed++
+		}
+		if o.timeout {
+			timeouts++
+		}
+	}
+
+	if timeouts > 0 {
+		fmt.Printf("%d searches stopped by the time limit before their depth, use a higher -time\n", timeouts)
+	}
+	fmt.Printf("%d/%d positions passed, %d nodes in %v\n", len(outcomes)-failed, len(outcomes), nodes, time.Since(start).Round(time.Millisecond))
+	if failed > 0 {
+		os.Exit(-1)
+	}
+}
+
+// readSuite reads the positions of an EPD suite.
+func readSuite(path string) ([]position, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var positions []position
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		p := position{fen: strings.TrimSpace(parts[0]), ops: map[string]string{}}
+		for _, op := range parts[1:] {
+			op = strings.TrimSpace(op)
+			if name, value, ok := strings.Cut(op, " "); ok {
+				p.ops[name] = strings.TrimSpace(value)
+			}
+		}
+
+		p.name = p.ops["id"]
+		if p.name == "" {
+			p.name = p.fen
+		}
+		positions = append(positions, p)
+	}
+
+	return positions, scanner.Err()
+}
+
+// run searches a position with a fresh hash table and checks the result.
+func run(e *engine.Engine, p position, limits engine.Limits) outcome {
+	if err := e.SetPosition(p.fen); err != nil {
+		return outcome{line: fmt.Sprintf("%s - %v %s", p.name, err, "❌"), failed: true}
+	}
+
+	e.NewGame()
+	r := e.Search(context.Background(), limits)
+	board := e.Board()
+
+	// the depth reached, flagged when the time limit cut the search short of the depth asked for
+	depth := fmt.Sprintf("depth %d", r.Depth)
+	timeout := r.Depth < limits.Depth
+	if timeout {
+		depth = fmt.Sprintf("depth %d of %d (time limit)", r.Depth, limits.Depth)
+	}
+
+	if err := checkResult(board, p.ops, r); err != nil {
+		return outcome{line: fmt.Sprintf("%s - %s %v %s", p.name, depth, err, "❌"), failed: true, timeout: timeout, nodes: r.Nodes}
+	}
+
+	return outcome{line: fmt.Sprintf("%s - %s %s %s %s", p.name, engine.FormatSAN(r.BestMove, board), depth, scoreString(r.Score), "✅"), timeout: timeout, nodes: r.Nodes}
+}
+
+// checkResult checks the result of the search against the bm, am and dm operations of the position.
+func checkResult(b *engine.Board, ops map[string]string, r engine.Result) error {
+	best := r.BestMove
+	if best == engine.NOMOVE {
+		return fmt.Errorf("no best move")
+	}
+	san := engine.FormatSAN(best, b)
+
+	if bm, ok := ops["bm"]; ok && !containsMove(b, bm, best) {
+		return fmt.Errorf("best move %s, expected %s", san, bm)
+	}
+	if am, ok := ops["am"]; ok && containsMove(b, am, best) {
+		return fmt.Errorf("best move %s, should avoid %s", san, am)
+	}
+	if dm, ok := ops["dm"]; ok {
+		n, err := strconv.Atoi(dm)
+		if err != nil {
+			return fmt.Errorf("invalid dm %q", dm)
+		}
+		if engine.MateMoves(r.Score) != n {
+			return fmt.Errorf("%s, expected mate %d", scoreString(r.Score), n)
+		}
+	}
+
+	return nil
+}
+
+// containsMove reports whether move is in a list of SAN moves.
+func containsMove(b *engine.Board, sanMoves string, move int) bool {
+	for _, san := range strings.Fields(sanMoves) {
+		if m, err := engine.ParseSAN(san, b); err == nil && m == move {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scoreString(score int) string {
+	if mate := engine.MateMoves(score); mate != 0 {
+		return fmt.Sprintf("mate %d", mate)
+	}
+
+	return fmt.Sprintf("cp %d", score)
+}
@@ -2,22 +2,44 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bbogdan95/alpaca/pkg/engine"
 	"github.com/bbogdan95/alpaca/pkg/perft"
 )
 
 func main() {
+	suite := flag.String("suite", "./perftsuite.epd", "perft suite to run")
+	maxDepth := flag.Int("depth", 6, "maximum depth to test")
+	chess960 := flag.Bool("chess960", false, "use Chess960 (Shredder-FEN) castling rights")
+	copyMake := flag.Bool("copymake", false, "take moves back by restoring the saved position instead of TakeMove")
+	parallel := flag.Int("parallel", 1, "number of goroutines sharing the moves of every position, each on a clone of the board")
+	flag.Parse()
+
 	engine.InitAll()
 
-	PerftTestSuite("./perftsuite.epd")
+	count := func(depth int, b *engine.Board) (uint64, error) {
+		return perft.PerftTest(depth, b, false)
+	}
+	if *copyMake {
+		count = perft.PerftCopyMake
+	}
+	if *parallel > 1 {
+		count = func(depth int, b *engine.Board) (uint64, error) {
+			return perft.PerftParallel(depth, b, *parallel)
+		}
+	}
+
+	PerftTestSuite(*suite, *maxDepth, *chess960, count)
 }
 
-func PerftTestSuite(filepath string) {
+// PerftTestSuite checks the perft counts of every position of the suite up to maxDepth, counting them with count.
+func PerftTestSuite(filepath string, maxDepth int, chess960 bool, count func(depth int, b *engine.Board) (uint64, error)) {
 	file, err := os.Open(filepath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
@@ -27,22 +49,49 @@ func PerftTestSuite(filepath string) {
 
 	scanner := bufio.NewScanner(file)
 
-	board := &engine.Board{}
+	board := &engine.Board{Chess960: chess960}
+
+	var totalNodes uint64
+	start := time.Now()
 
 	for scanner.Scan() {
 		line := scanner.Text()
 
 		parts := strings.Split(line, ";")
 
-		board.ParseFen(parts[0])
+		if err := board.ParseFen(parts[0]); err != nil {
+			fmt.Printf("%s\n - %v %s\n", parts[0], err, "❌")
+			os.Exit(-1)
+		}
 		board.CheckBoard()
 
 		fmt.Printf("%s\n", parts[0])
+
+		if fen := board.ToFen(); fen != strings.TrimSpace(parts[0]) {
+			fmt.Printf(" - fen round trip - %s %s\n", fen, "❌")
+			os.Exit(-1)
+		}
+		for _, move := range engine.LegalMoves(board) {
+			san := engine.FormatSAN(move, board)
+			spellings := []string{san}
+			if move&engine.MoveFlagEnPassant != 0 {
+				spellings = append(spellings, san+" e.p.", san+"e.p.")
+			}
+			for _, spelling := range spellings {
+				if parsed, err := engine.ParseSAN(spelling, board); err != nil || parsed != move {
+					fmt.Printf(" - san round trip - %q %s\n", spelling, "❌")
+					os.Exit(-1)
+				}
+			}
+		}
 		for _, test := range parts[1:] {
 			testParts := strings.Split(test, " ")
 
 			depthChar := testParts[0][1]
 			depthInt := int(depthChar - '0')
+			if depthInt > maxDepth {
+				continue
+			}
 
 			fmt.Printf(" - depth %d - %s - ", depthInt, testParts[1])
 
@@ -51,12 +100,13 @@ func PerftTestSuite(filepath string) {
 				panic(err)
 			}
 
-			leafNodes, err := perft.PerftTest(depthInt, board, false)
+			leafNodes, err := count(depthInt, board)
 			if err != nil {
 				panic(err)
 			}
 
 			fmt.Printf("%d", leafNodes)
+			totalNodes += leafNodes
 
 			if leafNodes != leafNodesCheck {
 				fmt.Printf("%s\n", "❌")
@@ -70,4 +120,7 @@ func PerftTestSuite(filepath string) {
 	if err := scanner.Err(); err != nil {
 		fmt.Println("Error reading file:", err)
 	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("\n%d nodes in %s (%.0f nodes/s)\n", totalNodes, elapsed, float64(totalNodes)/elapsed.Seconds())
 }
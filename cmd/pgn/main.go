@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+	"github.com/bbogdan95/alpaca/pkg/pgn"
+)
+
+func main() {
+	check := flag.Bool("check", false, "check that the exported games read back to the same output")
+	flag.Parse()
+
+	engine.InitAll()
+
+	var in io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Println("Error opening file:", err)
+			os.Exit(-1)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	games, err := pgn.NewReader(in).ReadAll()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	var out bytes.Buffer
+	if err := pgn.NewWriter(&out).WriteAll(games); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	if !*check {
+		os.Stdout.Write(out.Bytes())
+		return
+	}
+
+	// the games are replayed from their starting position, to check the moves read into the game
+	for i, g := range games {
+		b, err := g.Board()
+		if err != nil {
+			fmt.Printf("game %d: %v %s\n", i+1, err, "❌")
+			os.Exit(-1)
+		}
+		for _, m := range g.Moves {
+			if res, err := b.MakeMove(m.Move); err != nil || res == engine.FALSE {
+				fmt.Printf("game %d: illegal move %s %s\n", i+1, m.SAN, "❌")
+				os.Exit(-1)
+			}
+		}
+		fmt.Printf("game %d - %d moves - %s - %s\n", i+1, len(g.Moves), g.Result, b.ToFen())
+	}
+
+	again, err := pgn.NewReader(bytes.NewReader(out.Bytes())).ReadAll()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	var out2 bytes.Buffer
+	if err := pgn.NewWriter(&out2).WriteAll(again); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	if !bytes.Equal(out.Bytes(), out2.Bytes()) {
+		fmt.Printf("pgn round trip %s\n", "❌")
+		os.Exit(-1)
+	}
+	fmt.Printf("%d games, pgn round trip %s\n", len(games), "✅")
+}
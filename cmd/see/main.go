@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+/*
+Checks the static exchange evaluation against a suite of exchanges. Every line holds a FEN followed by operations
+separated by ";":
+
+	id name  -> name of the position
+	move m   -> the capture to evaluate (SAN)
+	see n    -> its expected value in centipawns
+*/
+
+func main() {
+	suite := flag.String("suite", "./seesuite.epd", "EPD suite of exchanges to check")
+	flag.Parse()
+
+	engine.InitAll()
+
+	file, err := os.Open(*suite)
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		os.Exit(-1)
+	}
+	defer file.Close()
+
+	b := &engine.Board{}
+	scanner := bufio.NewScanner(file)
+	failed, total := 0, 0
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		fen := strings.TrimSpace(parts[0])
+		ops := map[string]string{"id": fen}
+		for _, op := range parts[1:] {
+			if name, value, ok := strings.Cut(strings.TrimSpace(op), " "); ok {
+				ops[name] = strings.TrimSpace(value)
+			}
+		}
+
+		total++
+		if err := check(b, fen, ops); err != nil {
+			fmt.Printf("%s - %v %s\n", ops["id"], err, "❌")
+			failed++
+			continue
+		}
+		fmt.Printf("%s - %s %s %s\n", ops["id"], ops["move"], ops["see"], "✅")
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Println("Error reading file:", err)
+		os.Exit(-1)
+	}
+
+	fmt.Printf("%d/%d exchanges passed\n", total-failed, total)
+	if failed > 0 {
+		os.Exit(-1)
+	}
+}
+
+// check evaluates the exchange of a position and compares it with the expected value.
+func check(b *engine.Board, fen string, ops map[string]string) error {
+	if err := b.ParseFen(fen); err != nil {
+		return err
+	}
+
+	move, err := engine.ParseSAN(ops["move"], b)
+	if err != nil {
+		return err
+	}
+
+	want, err := strconv.Atoi(ops["see"])
+	if err != nil {
+		return fmt.Errorf("invalid see %q", ops["see"])
+	}
+
+	if got := engine.SEE(b, move); got != want {
+		return fmt.Errorf("%s: see %d, expected %d", ops["move"], got, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+/*
+Plays a match between two engines configured with different options, to measure what a change of the search is worth.
+Every opening of the suite is played twice, each engine having white once, and the match ends with the score of the
+first engine and the Elo difference it gives, along with a 95% error margin. Options are given as a comma separated
+list of name=value, for example:
+
+	selfplay -b "LateMoveReductions=false,LateMovePruning=false" -time 100
+*/
+
+// player is one of the two engines of the match.
+type player struct {
+	name   string
+	engine *engine.Engine
+}
+
+func main() {
+	openings := flag.String("openings", "./openings.epd", "starting positions, one FEN per line (EPD operations after ; are ignored but for id)")
+	optionsA := flag.String("a", "", "options of the first engine, name=value separated by commas")
+	optionsB := flag.String("b", "", "options of the second engine, name=value separated by commas")
+	movetime := flag.Int("time", 100, "search time per move in milliseconds")
+	hash := flag.Int("hash", 16, "hash table size in MB of each engine")
+	maxMoves := flag.Int("maxmoves", 150, "moves after which a game is adjudicated a draw")
+	flag.Parse()
+
+	fens, err := readOpenings(*openings)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		os.Exit(-1)
+	}
+
+	a, err := newPlayer("A", *hash, *optionsA)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	b, err := newPlayer("B", *hash, *optionsB)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	limits := engine.Limits{MoveTime: time.Duration(*movetime) * time.Millisecond}
+	wins, draws, losses := 0, 0, 0
+	start := time.Now()
+
+	for i, fen := range fens {
+		for _, white := range []bool{true, false} {
+			w, bl := a, b
+			if !white {
+				w, bl = b, a
+			}
+
+			result, reason, err := play(w, bl, fen, limits, *maxMoves)
+			if err != nil {
+				fmt.Printf("opening %d - %v\n", i+1, err)
+				os.Exit(-1)
+			}
+
+			switch {
+			case result == "1/2-1/2":
+				draws++
+			case (result == "1-0") == white:
+				wins++
+			default:
+				losses++
+			}
+
+			fmt.Printf("opening %2d  %s-%s  %-7s {%s}  A %d-%d-%d\n", i+1, w.name, bl.name, result, reason, wins, draws, losses)
+		}
+	}
+
+	games := wins + draws + losses
+	score := (float64(wins) + float64(draws)/2) / float64(games)
+	elo, margin := eloDifference(wins, draws, losses)
+	fmt.Printf("\n%d games in %v: A %d wins, %d draws, %d losses, score %.1f%%, Elo %+.0f ± %.0f\n",
+		games, time.Since(start).Round(time.Second), wins, draws, losses, 100*score, elo, margin)
+}
+
+// newPlayer returns an engine with the hash size and the options given as name=value separated by commas.
+// The move overhead is set to 0 as the moves are passed without delay.
+func newPlayer(name string, hash int, options string) (*player, error) {
+	e := engine.NewEngine()
+	if err := e.SetOption("Hash", strconv.Itoa(hash)); err != nil {
+		return nil, err
+	}
+	if err := e.SetOption("Move Overhead", "0"); err != nil {
+		return nil, err
+	}
+
+	if err := e.SetOptions(options); err != nil {
+		return nil, fmt.Errorf("engine %s: %v", name, err)
+	}
+
+	return &player{name: name, engine: e}, nil
+}
+
+// readOpenings reads the starting positions of the match.
+func readOpenings(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var fens []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fen, _, _ := strings.Cut(line, ";")
+		fens = append(fens, strings.TrimSpace(fen))
+	}
+
+	return fens, scanner.Err()
+}
+
+// play plays a game from the position and returns its result and the reason for it. The engines are given the
+// position and the moves played before every search, as a GUI does, and the game is followed on a board of its own.
+func play(white, black *player, fen string, limits engine.Limits, maxMoves int) (string, string, error) {
+	board := &engine.Board{}
+	if err := board.SetPosition(fen, nil); err != nil {
+		return "", "", err
+	}
+	white.engine.NewGame()
+	black.engine.NewGame()
+
+	var moves []string
+	for {
+		if result, reason := engine.GameResult(board); result != "*" {
+			return result, reason, nil
+		}
+		if len(moves) >= 2*maxMoves {
+			return "1/2-1/2", "adjudicated", nil
+		}
+
+		p := white
+		if board.Side == engine.BLACK {
+			p = black
+		}
+		if err := p.engine.SetPosition(fen, moves...); err != nil {
+			return "", "", err
+		}
+
+		r := p.engine.Search(context.Background(), limits)
+		if r.BestMove == engine.NOMOVE {
+			return "", "", fmt.Errorf("engine %s found no move", p.name)
+		}
+
+		moves = append(moves, engine.FormatMove(r.BestMove, false))
+		board.MakeMove(r.BestMove)
+		board.Ply = 0
+	}
+}
+
+// eloDifference returns the Elo difference given by a score and its 95% error margin, from the variance of the
+// results of the games. A score of 0% or 100% is counted as half a game away from it.
+func eloDifference(wins, draws, losses int) (elo, margin float64) {
+	n := float64(wins + draws + losses)
+	score := (float64(wins) + float64(draws)/2) / n
+	score = math.Max(math.Min(score, 1-0.5/n), 0.5/n)
+
+	variance := (float64(wins)*math.Pow(1-score, 2) + float64(draws)*math.Pow(0.5-score, 2) + float64(losses)*math.Pow(score, 2)) / n
+	deviation := math.Sqrt(variance / n)
+
+	low := math.Max(score-1.96*deviation, 0.5/n)
+	high := math.Min(score+1.96*deviation, 1-0.5/n)
+
+	return eloFromScore(score), (eloFromScore(high) - eloFromScore(low)) / 2
+}
+
+func eloFromScore(score float64) float64 {
+	return -400 * math.Log10(1/score-1)
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+// uciSession drives engine.UCILoop through a pipe, like a GUI would.
+type uciSession struct {
+	in      *io.PipeWriter
+	lines   chan string
+	done    chan error
+	err     error // Error returned by UCILoop, once it exited.
+	quitted bool
+}
+
+func newSession() *uciSession {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	board := &engine.Board{}
+	engine.InitHashTable(board, 16)
+	s := &engine.SearchInfo{Input: engine.NewInput(inR), Output: outW}
+
+	u := &uciSession{in: inW, lines: make(chan string, 1024), done: make(chan error, 1)}
+
+	go func() {
+		u.done <- engine.UCILoop(board, s)
+		outW.Close()
+	}()
+
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			u.lines <- scanner.Text()
+		}
+		close(u.lines)
+	}()
+
+	u.expect("uciok", time.Second)
+
+	return u
+}
+
+func (u *uciSession) send(command string) {
+	fmt.Fprintln(u.in, command)
+}
+
+// expect returns the lines written by the engine up to the first one starting with prefix.
+// The error is set if the line did not come within the timeout.
+func (u *uciSession) expect(prefix string, timeout time.Duration) ([]string, error) {
+	var lines []string
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case line, ok := <-u.lines:
+			if !ok {
+				return lines, fmt.Errorf("engine exited while waiting for %q", prefix)
+			}
+			lines = append(lines, line)
+			if strings.HasPrefix(line, prefix) {
+				return lines, nil
+			}
+		case <-deadline:
+			return lines, fmt.Errorf("no %q after %v", prefix, timeout)
+		}
+	}
+}
+
+// quit makes the engine quit and returns the error of UCILoop. It can be called several times.
+func (u *uciSession) quit() error {
+	if u.quitted {
+		return u.err
+	}
+
+	u.send("quit")
+	select {
+	case u.err = <-u.done:
+	case <-time.After(5 * time.Second):
+		u.err = fmt.Errorf("engine did not quit")
+	}
+	u.quitted = true
+
+	return u.err
+}
+
+// infoValue returns the integer following key in an info line, or -1.
+func infoValue(line, key string) int {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == key {
+			v, err := strconv.Atoi(fields[i+1])
+			if err == nil {
+				return v
+			}
+		}
+	}
+
+	return -1
+}
+
+// lastInfo returns the last info line with a score in the lines.
+func lastInfo(lines []string) string {
+	info := ""
+	for _, line := range lines {
+		if strings.HasPrefix(line, "info") && strings.Contains(line, " score ") {
+			info = line
+		}
+	}
+
+	return info
+}
+
+type check struct {
+	name string
+	run  func(u *uciSession) error
+}
+
+var checks = []check{
+	{"go wtime/btime uses the clock of the side to move", func(u *uciSession) error {
+		tests := []struct {
+			position, command string
+			t                 time.Duration
+		}{
+			// 60000 / 10 - 50
+			{"position startpos", "go wtime 60000 btime 1000 winc 500 binc 0 movestogo 10", 5950 * time.Millisecond},
+			// 1000 / 10 - 50
+			{"position startpos moves e2e4", "go wtime 60000 btime 1000 winc 500 binc 0 movestogo 10", 50 * time.Millisecond},
+			// the clock split over movestogo
+			{"position startpos", "go wtime 10000 btime 10000 movestogo 2", 4950 * time.Millisecond},
+			// 1000 / 30 is below the overhead, half of it is kept
+			{"position startpos moves e2e4", "go wtime 60000 btime 1000", 16 * time.Millisecond},
+		}
+		for _, test := range tests {
+			t, err := timeForMove(test.position, test.command)
+			if err != nil {
+				return err
+			}
+			if t != test.t {
+				return fmt.Errorf("%s, %s: time %v, expected %v", test.position, test.command, t, test.t)
+			}
+		}
+		return nil
+	}},
+	{"go with a clock lower than the move overhead", func(u *uciSession) error {
+		u.send("position startpos moves e2e4")
+		u.send("go wtime 60000 btime 1000")
+		lines, err := u.expect("bestmove", time.Second)
+		if err != nil {
+			return err
+		}
+		// 1000 / 30 is below the overhead of 50ms, the move is still searched
+		if depth := infoValue(lastInfo(lines), "depth"); depth < 1 {
+			return fmt.Errorf("bestmove %s without a search", strings.Fields(lines[len(lines)-1])[1])
+		}
+		return nil
+	}},
+	{"go infinite waits for stop", func(u *uciSession) error {
+		u.send("position startpos")
+		u.send("go infinite")
+		if lines, err := u.expect("bestmove", 500*time.Millisecond); err == nil {
+			return fmt.Errorf("bestmove before stop: %s", lines[len(lines)-1])
+		}
+		u.send("isready")
+		if _, err := u.expect("readyok", time.Second); err != nil {
+			return fmt.Errorf("isready during the search: %v", err)
+		}
+		u.send("stop")
+		_, err := u.expect("bestmove", time.Second)
+		return err
+	}},
+	{"go infinite waits for stop after the last iteration", func(u *uciSession) error {
+		u.send("position startpos")
+		u.send("go infinite depth 2")
+		if _, err := u.expect("bestmove", 500*time.Millisecond); err == nil {
+			return fmt.Errorf("bestmove before stop")
+		}
+		u.send("stop")
+		_, err := u.expect("bestmove", time.Second)
+		return err
+	}},
+	{"go nodes", func(u *uciSession) error {
+		u.send("ucinewgame")
+		u.send("position startpos")
+		u.send("go nodes 20000")
+		lines, err := u.expect("bestmove", 10*time.Second)
+		if err != nil {
+			return err
+		}
+		// the node count is checked every 2048 nodes
+		if nodes := infoValue(lastInfo(lines), "nodes"); nodes > 20000+2048 {
+			return fmt.Errorf("%d nodes searched", nodes)
+		}
+		return nil
+	}},
+	{"go mate", func(u *uciSession) error {
+		u.send("position fen r2qkb1r/pp2nppp/3p4/2pNN1B1/2BnP3/3P4/PPP2PPP/R2bK2R w KQkq - 1 1")
+		u.send("go mate 2")
+		lines, err := u.expect("bestmove", 30*time.Second)
+		if err != nil {
+			return err
+		}
+		if best := strings.Fields(lines[len(lines)-1])[1]; best != "d5f6" {
+			return fmt.Errorf("bestmove %s, expected d5f6", best)
+		}
+		if depth := infoValue(lastInfo(lines), "depth"); depth > 4 {
+			return fmt.Errorf("searched to depth %d", depth)
+		}
+		return nil
+	}},
+	{"go searchmoves", func(u *uciSession) error {
+		u.send("position startpos")
+		u.send("go depth 4 searchmoves a2a3 h2h3")
+		lines, err := u.expect("bestmove", 10*time.Second)
+		if err != nil {
+			return err
+		}
+		if best := strings.Fields(lines[len(lines)-1])[1]; best != "a2a3" && best != "h2h3" {
+			return fmt.Errorf("bestmove %s, expected a2a3 or h2h3", best)
+		}
+		for _, line := range lines {
+			if pv := strings.Index(line, " pv "); pv >= 0 {
+				if move := strings.Fields(line[pv:])[1]; move != "a2a3" && move != "h2h3" {
+					return fmt.Errorf("pv starting with %s", move)
+				}
+			}
+		}
+		return nil
+	}},
+	{"bestmove with a ponder move", func(u *uciSession) error {
+		u.send("position startpos")
+		u.send("go depth 4")
+		lines, err := u.expect("bestmove", 10*time.Second)
+		if err != nil {
+			return err
+		}
+		if fields := strings.Fields(lines[len(lines)-1]); len(fields) != 4 || fields[2] != "ponder" {
+			return fmt.Errorf("%q has no ponder move", lines[len(lines)-1])
+		}
+		return nil
+	}},
+	{"go ponder waits for ponderhit", func(u *uciSession) error {
+		u.send("position startpos moves e2e4 e7e5")
+		u.send("go ponder wtime 1000 btime 1000 movestogo 1")
+		// the 950ms of the move only start at ponderhit
+		if _, err := u.expect("bestmove", 1500*time.Millisecond); err == nil {
+			return fmt.Errorf("bestmove before ponderhit")
+		}
+		start := time.Now()
+		u.send("ponderhit")
+		if _, err := u.expect("bestmove", 3*time.Second); err != nil {
+			return err
+		}
+		if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+			return fmt.Errorf("bestmove %v after ponderhit", elapsed)
+		}
+		return nil
+	}},
+	{"go ponder waits for ponderhit after the last iteration", func(u *uciSession) error {
+		u.send("position startpos moves e2e4 e7e5")
+		u.send("go ponder depth 2 wtime 1000 btime 1000")
+		if _, err := u.expect("bestmove", 500*time.Millisecond); err == nil {
+			return fmt.Errorf("bestmove before ponderhit")
+		}
+		u.send("ponderhit")
+		_, err := u.expect("bestmove", time.Second)
+		return err
+	}},
+	{"stop during go ponder", func(u *uciSession) error {
+		u.send("position startpos moves e2e4 e7e5")
+		u.send("go ponder wtime 1000 btime 1000")
+		time.Sleep(200 * time.Millisecond)
+		u.send("stop")
+		_, err := u.expect("bestmove", time.Second)
+		return err
+	}},
+	{"MultiPV", func(u *uciSession) error {
+		u.send("setoption name MultiPV value 3")
+		u.send("position startpos")
+		u.send("go depth 4")
+		lines, err := u.expect("bestmove", 10*time.Second)
+		if err != nil {
+			return err
+		}
+
+		// the lines of the last iteration, ranked from the best
+		var moves []string
+		var scores []int
+		for _, line := range lines {
+			if infoValue(line, "depth") != 4 {
+				continue
+			}
+			if k := infoValue(line, "multipv"); k != len(moves)+1 {
+				return fmt.Errorf("multipv %d after %d lines", k, len(moves))
+			}
+			pv := strings.Index(line, " pv ")
+			moves = append(moves, strings.Fields(line[pv:])[1])
+			scores = append(scores, infoValue(line, "cp"))
+		}
+
+		if len(moves) != 3 {
+			return fmt.Errorf("%d lines at depth 4, expected 3", len(moves))
+		}
+		if moves[0] == moves[1] || moves[0] == moves[2] || moves[1] == moves[2] {
+			return fmt.Errorf("lines starting with the same move: %v", moves)
+		}
+		if scores[0] < scores[1] || scores[1] < scores[2] {
+			return fmt.Errorf("lines not ranked by score: %v", scores)
+		}
+		if best := strings.Fields(lines[len(lines)-1])[1]; best != moves[0] {
+			return fmt.Errorf("bestmove %s, first line %s", best, moves[0])
+		}
+		return nil
+	}},
+	{"MultiPV with fewer moves than lines", func(u *uciSession) error {
+		u.send("setoption name MultiPV value 5")
+		u.send("position startpos")
+		u.send("go depth 2 searchmoves e2e4 d2d4")
+		lines, err := u.expect("bestmove", 10*time.Second)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if infoValue(line, "multipv") > 2 {
+				return fmt.Errorf("%q with 2 searchmoves", line)
+			}
+		}
+		return nil
+	}},
+	{"info fields", func(u *uciSession) error {
+		u.send("position startpos")
+		u.send("go depth 5")
+		lines, err := u.expect("bestmove", 10*time.Second)
+		if err != nil {
+			return err
+		}
+		info := lastInfo(lines)
+		for _, key := range []string{"depth", "seldepth", "nodes", "nps", "hashfull", "time"} {
+			if infoValue(info, key) < 0 {
+				return fmt.Errorf("no %s in %q", key, info)
+			}
+		}
+		if infoValue(info, "seldepth") < infoValue(info, "depth") {
+			return fmt.Errorf("seldepth lower than depth in %q", info)
+		}
+		return nil
+	}},
+	{"info currmove", func(u *uciSession) error {
+		u.send("position startpos")
+		u.send("go infinite")
+
+		// the root moves are only reported after a second, and a single one can take a few seconds to search
+		found := false
+		timeout := time.After(10 * time.Second)
+		for !found {
+			select {
+			case line, ok := <-u.lines:
+				if !ok {
+					return fmt.Errorf("engine exited")
+				}
+				found = strings.Contains(line, " currmove ") && infoValue(line, "currmovenumber") > 0
+			case <-timeout:
+				u.send("stop")
+				u.expect("bestmove", 5*time.Second)
+				return fmt.Errorf("no currmove after 10 seconds of search")
+			}
+		}
+
+		u.send("stop")
+		_, err := u.expect("bestmove", 5*time.Second)
+		return err
+	}},
+	{"position with a move leaving the king in check", func(u *uciSession) error {
+		u.send("position fen 4k3/4r3/8/8/8/8/8/4K3 w - - 0 1 moves e1e2")
+		lines, err := u.expect("info string", time.Second)
+		if err != nil {
+			return err
+		}
+		if reply := lines[len(lines)-1]; reply != "info string illegal move \"e1e2\"" {
+			return fmt.Errorf("%q, expected the move to be rejected", reply)
+		}
+		return nil
+	}},
+	{"setoption", func(u *uciSession) error {
+		tests := []struct{ command, reply string }{
+			{"setoption name Move Overhead value 100", "info string Move Overhead set to 100"},
+			{"setoption name threads value 2", "info string Threads set to 2"},
+			{"setoption name SyzygyPath value /tb/wdl:/tb/dtz", "info string SyzygyPath set to /tb/wdl:/tb/dtz"},
+			{"setoption name Hash value 1", "info string invalid value 1 for option Hash: not between 4 and 2048"},
+			{"setoption name OwnBook value maybe", "info string invalid value \"maybe\" for option OwnBook: not true or false"},
+			{"setoption name No Such Option value 3", "info string unknown option \"No Such Option\""},
+		}
+		for _, t := range tests {
+			u.send(t.command)
+			lines, err := u.expect("info string", time.Second)
+			if err != nil {
+				return fmt.Errorf("%s: %v", t.command, err)
+			}
+			if reply := lines[len(lines)-1]; reply != t.reply {
+				return fmt.Errorf("%s: %q, expected %q", t.command, reply, t.reply)
+			}
+		}
+		// buttons have no value and no reply
+		u.send("setoption name Clear Hash")
+		u.send("isready")
+		lines, err := u.expect("readyok", time.Second)
+		if err != nil {
+			return err
+		}
+		if len(lines) > 1 {
+			return fmt.Errorf("Clear Hash: %q", lines[0])
+		}
+		return nil
+	}},
+	{"quit during a search", func(u *uciSession) error {
+		u.send("position startpos")
+		u.send("go infinite")
+		time.Sleep(200 * time.Millisecond)
+		return u.quit()
+	}},
+}
+
+// timeForMove returns the time the engine gives to the move of a go command in a position, without the increment.
+func timeForMove(position, command string) (time.Duration, error) {
+	b := &engine.Board{}
+	if err := b.ParsePosition(position); err != nil {
+		return 0, err
+	}
+
+	t, ok := b.ParseGo(command).TimeForMove(b.Side, engine.DefaultMoveOverhead)
+	if !ok {
+		return 0, fmt.Errorf("%s: no time limit", command)
+	}
+
+	return t, nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s\n\nRuns the checks of the UCI go command against the engine.\n", os.Args[0])
+	}
+	flag.Parse()
+
+	engine.InitAll()
+
+	failed := 0
+	for _, c := range checks {
+		u := newSession()
+		err := c.run(u)
+		if qerr := u.quit(); err == nil {
+			err = qerr
+		}
+
+		if err != nil {
+			failed++
+			fmt.Printf("%s - %v %s\n", c.name, err, "❌")
+		} else {
+			fmt.Printf("%s %s\n", c.name, "✅")
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d checks failed\n", failed, len(checks))
+		os.Exit(-1)
+	}
+}
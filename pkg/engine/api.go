@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+Engine is the entry point for programs embedding Alpaca: it owns a position, a hash table and the options, and searches
+without reading or writing anything. The progress of a search is reported through callbacks and its outcome is
+returned as a Result. The UCI, xboard and console loops are built on it, turning the callbacks into their own output.
+
+	e := engine.NewEngine()
+	e.SetPosition("startpos", "e2e4", "e7e5")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res := e.Search(ctx, engine.Limits{Depth: 12})
+	fmt.Println(engine.FormatMove(res.BestMove, false), res.Score)
+*/
+
+// Limits are the limits of a search, their zero value meaning no limit.
+type Limits struct {
+	Depth     int           // Maximum depth in plies.
+	MoveTime  time.Duration // Time for the move, used instead of the clock.
+	WTime     time.Duration // Clock of white. The clock and increment of the side to move give the time for the move.
+	BTime     time.Duration // Clock of black.
+	WInc      time.Duration // Increment of white.
+	BInc      time.Duration // Increment of black.
+	MovesToGo int           // Moves to the next time control, the clock is split over 30 moves if not set.
+	Nodes     uint64        // Stop after searching about this many nodes.
+	Mate      int           // Stop once a mate in this many moves is found.
+
+	SearchMoves []int // Only search these root moves.
+
+	Infinite bool // Search until the context is cancelled, even once the best move is known.
+	Ponder   bool // Search on the opponent's time: no time limit until PonderHit, the search ends when the context is cancelled.
+}
+
+// TimeForMove returns the time for the move from the clock of the side to move, split over the moves to go and less
+// the move overhead (in milliseconds). When the overhead would take all of it, half of the split is used instead,
+// and never less than a millisecond. The increment is added to it when searching. ok is false without a time limit.
+func (l Limits) TimeForMove(side, overhead int) (t time.Duration, ok bool) {
+	t, movestogo := l.WTime, l.MovesToGo
+	if side == BLACK {
+		t = l.BTime
+	}
+	if movestogo <= 0 {
+		movestogo = 30
+	}
+
+	if l.MoveTime > 0 {
+		t, movestogo = l.MoveTime, 1
+	}
+
+	if t <= 0 || l.Infinite {
+		return 0, false
+	}
+
+	split := t / time.Millisecond / time.Duration(movestogo)
+	if split > time.Duration(overhead) {
+		return (split - time.Duration(overhead)) * time.Millisecond, true
+	}
+	if split < 2 {
+		return time.Millisecond, true
+	}
+
+	return split / 2 * time.Millisecond, true
+}
+
+// increment returns the increment of the side to move.
+func (l Limits) increment(side int) time.Duration {
+	if side == BLACK {
+		return l.BInc
+	}
+
+	return l.WInc
+}
+
+// setLimits prepares the search info for a search of the board within the given limits.
+func (s *SearchInfo) setLimits(b *Board, l Limits) {
+	s.StartTime = time.Now()
+	s.Timeset = FALSE
+	s.Infinite = FALSE
+	s.Ponder = FALSE
+	s.NodesLimit = l.Nodes
+	s.Mate = 0
+	s.SearchMoves = l.SearchMoves
+
+	if l.Infinite {
+		s.Infinite = TRUE
+	}
+
+	if t, ok := l.TimeForMove(b.Side, s.MoveOverhead); ok {
+		s.Timeset = TRUE
+		s.ponderTime = t + l.increment(b.Side)
+		s.StopTime = s.StartTime.Add(s.ponderTime)
+	}
+
+	if l.Ponder {
+		s.Ponder = TRUE
+		s.ponderTimeset = s.Timeset
+		s.Timeset = FALSE
+	}
+
+	s.Depth = l.Depth
+	if s.Depth <= 0 || s.Depth > MAXDEPTH {
+		s.Depth = MAXDEPTH
+	}
+
+	// a mate in n moves is found at 2n plies, the last one seeing that the side to move is mated
+	if l.Mate > 0 {
+		s.Mate = l.Mate
+		if 2*l.Mate < s.Depth {
+			s.Depth = 2 * l.Mate
+		}
+	}
+}
+
+// Info is the progress of a search, reported after every iteration (after every line of it with MultiPV).
+type Info struct {
+	Depth    int
+	SelDepth int           // Deepest ply reached by the iteration, quiescence included.
+	MultiPV  int           // Number of the line, 1 for the best one.
+	Score    int           // Centipawns for the side to move, or a mate score (see MateMoves).
+	Bound    int           // HFEXACT, or HFBETA / HFALPHA when the score is only a lower / upper bound.
+	Nodes    uint64        // Nodes searched by all the threads.
+	Time     time.Duration // Time since the start of the search.
+	Hashfull int           // Use of the hash table, in permille.
+	PV       []int
+}
+
+// NPS returns the number of nodes searched per second.
+func (i Info) NPS() uint64 {
+	if ms := uint64(i.Time.Milliseconds()); ms > 0 {
+		return i.Nodes * 1000 / ms
+	}
+
+	return 0
+}
+
+// Result is the outcome of a search.
+type Result struct {
+	BestMove   int // NOMOVE if the side to move has no legal move.
+	PonderMove int // Expected reply to the best move, NOMOVE if none is known.
+	Score      int // Score of the last iteration, 0 for a book move.
+	PV         []int
+	Book       bool // The best move comes from the opening book, there was no search.
+
+	Depth    int // Last iteration completed.
+	SelDepth int
+	Nodes    uint64
+	Time     time.Duration
+}
+
+// Engine is a chess engine that can be embedded in another program.
+// Its methods must not be called concurrently, except for PonderHit while Search is running.
+type Engine struct {
+	// Progress, if set, is called by Search after every iteration, for every line with MultiPV.
+	Progress func(Info)
+	// CurrMove, if set, is called by Search when it starts on a root move, once it has been searching for a second.
+	CurrMove func(depth, move, number int)
+
+	board *Board
+	info  *SearchInfo
+}
+
+// NewEngine returns an engine set to the starting position, with the default options.
+// Engines are independent of each other and can search at the same time.
+func NewEngine() *Engine {
+	InitAll()
+
+	b := &Board{}
+	InitHashTable(b, 64)
+	b.ParseFen(START_FEN)
+
+	s := &SearchInfo{MoveOverhead: DefaultMoveOverhead, ponderhit: make(chan struct{}, 1)}
+
+	return &Engine{board: b, info: s}
+}
+
+// newEngine returns an engine searching the board of a protocol loop, with its search info.
+func newEngine(b *Board, s *SearchInfo) *Engine {
+	return &Engine{board: b, info: s}
+}
+
+// Board returns the position of the engine. It must not be changed during a search.
+func (e *Engine) Board() *Board {
+	return e.board
+}
+
+// SetPosition sets the position from a FEN, or "startpos", and the moves played from it in UCI notation.
+func (e *Engine) SetPosition(fen string, moves ...string) error {
+	return e.board.SetPosition(fen, moves)
+}
+
+// SetOption sets one of the Options.
+func (e *Engine) SetOption(name, value string) error {
+	return SetOption(e.board, e.info, name, value)
+}
+
+// SetOptions sets the options given as name=value separated by commas ("Hash=64,NullMovePruning=false"), as taken
+// by the -options flags of the tools. It stops at the first option that cannot be set.
+func (e *Engine) SetOptions(list string) error {
+	for _, option := range strings.Split(list, ",") {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return fmt.Errorf("option %q is not name=value", strings.TrimSpace(option))
+		}
+		if err := e.SetOption(strings.TrimSpace(name), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewGame clears the hash table, forgetting the previous searches.
+func (e *Engine) NewGame() {
+	ClearHashTable(e.board)
+}
+
+// Search searches the position within the limits until one of them is reached or the context is cancelled, and
+// returns the best move found. Infinite and ponder searches only return once the context is cancelled (or, for a
+// ponder search, once PonderHit was called and the time is up).
+func (e *Engine) Search(ctx context.Context, l Limits) Result {
+	b, s := e.board, e.info
+
+	s.setLimits(b, l)
+	s.ctx, s.progress, s.currMove = ctx, e.Progress, e.CurrMove
+	defer func() {
+		s.ctx, s.progress, s.currMove = nil, nil, nil
+	}()
+
+	// a ponderhit sent after the end of the previous search does not concern this one
+	select {
+	case <-s.ponderhit:
+	default:
+	}
+
+	return SearchPosition(b, s)
+}
+
+// PonderHit tells a ponder search that the opponent played the expected move: the time of the move starts now.
+// It may be called while Search is running.
+func (e *Engine) PonderHit() {
+	select {
+	case e.info.ponderhit <- struct{}{}:
+	default:
+	}
+}
@@ -2,27 +2,17 @@ package engine
 
 import (
 	"fmt"
+	"io"
 )
 
-// KnightDir represents the possible move directions for a knight on the chessboard.
-// The knight can move in an L-shape: two squares in one direction and one square perpendicular to that direction.
-// The array contains relative square indices representing these knight move directions.
-var KnightDir = [8]int{-8, -19, -21, -12, 8, 19, 21, 12}
-
-// RookDir represents the possible move directions for a rook on the chessboard.
-// Rooks can move vertically (along files) or horizontally (along ranks).
-// The array contains relative square indices representing these rook move directions.
-var RookDir = [4]int{-1, -10, 1, 10}
-
-// BishopDir represents the possible move directions for a bishop on the chessboard.
-// Bishops can move diagonally.
-// The array contains relative square indices representing these bishop move directions.
-var BishopDir = [4]int{-9, -11, 11, 9}
-
-// KingDir represents the possible move directions for a king on the chessboard.
-// Kings can move one square in any direction: horizontally, vertically, or diagonally.
-// The array contains relative square indices representing these king move directions.
-var KingDir = [8]int{-1, -10, 1, 10, -9, -11, 11, 9}
+// SidePawn, SideKnight, SideBishop, SideRook, SideQueen and SideKing map a side (WHITE or BLACK)
+// to the corresponding piece of that color, so that piece bitboards can be looked up by side.
+var SidePawn = [2]int{WP, BP}
+var SideKnight = [2]int{WN, BN}
+var SideBishop = [2]int{WB, BB}
+var SideRook = [2]int{WR, BR}
+var SideQueen = [2]int{WQ, BQ}
+var SideKing = [2]int{WK, BK}
 
 // SqAttacked determines if a specific square on the chessboard is attacked by a given side.
 //
@@ -38,8 +28,9 @@ var KingDir = [8]int{-1, -10, 1, 10, -9, -11, 11, 9}
 //
 // Note:
 //
-//	The function handles pawns, knights, rooks, bishops, queens, and kings to determine attacks.
-//	It performs boundary checks and ensures the square and side are valid before processing.
+//	The attacks are looked up from the square itself: a piece of the given side attacks sq
+//	if sq attacks it with the same piece type (pawns use the attacks of the opposite color).
+//	Sliding pieces use the magic bitboard tables.
 func SqAttacked(sq int, side int, b *Board) int {
 
 	if !SqOnBoard(sq) || !SideValid(side) {
@@ -48,91 +39,66 @@ func SqAttacked(sq int, side int, b *Board) int {
 
 	b.CheckBoard()
 
-	// pawns
-	if side == WHITE {
-		if b.Pieces[sq-11] == WP || b.Pieces[sq-9] == WP {
-			return TRUE
-		}
-	} else {
-		if b.Pieces[sq+11] == BP || b.Pieces[sq+9] == BP {
-			return TRUE
-		}
-	}
-
-	// knights
-	for i := 0; i < 8; i++ {
-		piece := b.Pieces[sq+KnightDir[i]]
-		if piece != OFFBOARD && piece != EMPTY && PieceKnight[piece] == TRUE && PieceCol[piece] == side {
-			return TRUE
-		}
+	if AttackersOf(SQ64[sq], side, b.Occupancy[BOTH], &b.Bitboards) != 0 {
+		return TRUE
 	}
 
-	// rooks, queens
-	for i := 0; i < 4; i++ {
-		dir := RookDir[i]
-		tSq := sq + dir
-		piece := b.Pieces[tSq]
+	return FALSE
+}
 
-		for piece != OFFBOARD {
-			if piece != EMPTY {
-				if PieceRookQueen[piece] == TRUE && PieceCol[piece] == side {
-					return TRUE
-				}
-				break
-			}
-			tSq += dir
-			piece = b.Pieces[tSq]
-		}
-	}
+// AttackersOf returns a bitboard of the pieces of the given side attacking sq (64-square indexing),
+// given the piece bitboards and using occ as the board occupancy for sliding pieces.
+func AttackersOf(sq int, side int, occ uint64, bb *[13]uint64) uint64 {
+	queens := bb[SideQueen[side]]
 
-	// bishops, queen
-	for i := 0; i < 4; i++ {
-		dir := BishopDir[i]
-		tSq := sq + dir
-		piece := b.Pieces[tSq]
+	return PawnAttacks[side^1][sq]&bb[SidePawn[side]] |
+		KnightAttacks[sq]&bb[SideKnight[side]] |
+		KingAttacks[sq]&bb[SideKing[side]] |
+		BishopAttacks(sq, occ)&(bb[SideBishop[side]]|queens) |
+		RookAttacks(sq, occ)&(bb[SideRook[side]]|queens)
+}
 
-		for piece != OFFBOARD {
-			if piece != EMPTY {
-				if PieceBishopQueen[piece] == TRUE && PieceCol[piece] == side {
-					return TRUE
-				}
-				break
-			}
-			tSq += dir
-			piece = b.Pieces[tSq]
-		}
+// PieceAttacks returns the squares attacked by a knight, bishop, rook, queen or king on sq
+// (64-square indexing) given the board occupancy.
+func PieceAttacks(piece int, sq int, occ uint64) uint64 {
+	if PieceKnight[piece] == TRUE {
+		return KnightAttacks[sq]
+	}
+	if PieceKing[piece] == TRUE {
+		return KingAttacks[sq]
 	}
 
-	// kings
-	for i := 0; i < 8; i++ {
-		piece := b.Pieces[sq+KingDir[i]]
-		if piece != OFFBOARD && piece != EMPTY && PieceKing[piece] == TRUE && PieceCol[piece] == side {
-			return TRUE
-		}
+	var attacks uint64
+	if PieceBishopQueen[piece] == TRUE {
+		attacks |= BishopAttacks(sq, occ)
+	}
+	if PieceRookQueen[piece] == TRUE {
+		attacks |= RookAttacks(sq, occ)
 	}
 
-	return FALSE
+	return attacks
 }
 
 // ShowSqAttackedBySide prints the squares attacked by the specified side on the given chessboard.
 //
 // Parameters:
 //
+//	out: Where the squares are printed to.
 //	side: The side for which the attacked squares are to be displayed. Use constants WHITE or BLACK.
 //	b: A pointer to the chessboard for which the attacked squares are to be calculated.
-func ShowSqAttackedBySide(side int, b *Board) {
-	fmt.Printf("\n\nSquares attacked by: %c\n", SideChar[side])
+func ShowSqAttackedBySide(out io.Writer, side int, b *Board) {
+	fmt.Fprintf(out, "\n\nSquares attacked by: %c\n", SideChar[side])
 
 	for rank := RANK_8; rank >= RANK_1; rank-- {
 		for file := FILE_A; file <= FILE_H; file++ {
 			sq := FR2SQ(file, rank)
 			if SqAttacked(sq, side, b) == TRUE {
-				fmt.Printf(" X ")
+				fmt.Fprintf(out, " X ")
 			} else {
-				fmt.Printf(" - ")
+				fmt.Fprintf(out, " - ")
 			}
 		}
-		fmt.Printf("\n")
+		fmt.Fprintf(out, "\n")
 	}
-	fmt.Printf("\n\n")
+	fmt.Fprintf(out, "\n\n")
 }
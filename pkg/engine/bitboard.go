@@ -3,6 +3,7 @@ package engine
 import (
 	"fmt"
 	"io"
+	"math/bits"
 )
 
 // SetMask is an array of 64 uint64 values, each containing a single bit set at a specific index.
@@ -13,12 +14,6 @@ var SetMask [64]uint64
 // It is used to clear individual bits in a uint64 bitboard.
 var ClearMask [64]uint64
 
-// BitTable is an array that maps a 6-bit value (0-63) to the index of the least significant set bit (LS1B) in binary representation.
-// It is used for efficient bit manipulation operations like finding the LS1B index.
-var BitTable = []int{
-	63, 30, 3, 32, 25, 41, 22, 33, 15, 50, 42, 13, 11, 53, 19, 34, 61, 29, 2, 51, 21, 43, 45, 10, 18, 47, 1, 54, 9, 57, 0, 35, 62, 31, 40, 4, 49, 5, 52, 26, 60, 6, 23, 44, 46, 27, 56, 16, 7, 39, 48, 24, 59, 14, 12, 55, 38, 28, 58, 20, 37, 17, 36, 8,
-}
-
 // PrintBitboard prints a human-readable representation of a bitboard to the specified output writer.
 // 'X' is used to represent set bits, and '-' is used to represent cleared bits.
 func PrintBitboard(out io.Writer, bitboard uint64) {
@@ -49,6 +44,8 @@ func PrintBitboard(out io.Writer, bitboard uint64) {
 func (b *Board) MirrorBoard() {
 	tempPieces := [64]int{}
 	tempSide := b.Side ^ 1
+	tempFiftyMove := b.FiftyMove
+	tempFullMove := b.FullMove
 	tempCastlePerm := 0
 	tempEnPassant := NO_SQ
 
@@ -74,6 +71,12 @@ func (b *Board) MirrorBoard() {
 		tempEnPassant = SQ120[Mirror64[SQ64[b.EnPassant]]]
 	}
 
+	tempCastleRooks := [4]int{}
+	for i := 0; i < 4; i++ {
+		// white rights become black rights and vice versa: 0 <-> 2, 1 <-> 3
+		tempCastleRooks[i^2] = SQ120[Mirror64[SQ64[b.CastleRooks[i]]]]
+	}
+
 	for sq := 0; sq < 64; sq++ {
 		tempPieces[sq] = b.Pieces[SQ120[Mirror64[sq]]]
 	}
@@ -87,11 +90,15 @@ func (b *Board) MirrorBoard() {
 
 	b.Side = tempSide
 	b.CastlePerm = tempCastlePerm
+	b.CastleRooks = tempCastleRooks
 	b.EnPassant = tempEnPassant
+	b.FiftyMove = tempFiftyMove
+	b.FullMove = tempFullMove
 
 	b.PosKey = GeneratePosKey(b)
 
 	UpdateListsMaterial(b)
+	b.InitCastleMasks()
 
 	b.CheckBoard()
 }
@@ -99,23 +106,16 @@ func (b *Board) MirrorBoard() {
 // PopBit finds and clears the least significant set bit (LS1B) in a uint64 bitboard.
 // It returns the index (0-63) of the LS1B that was cleared.
 // This function is used for efficiently finding and removing individual set bits from a bitboard.
-// The BitTable array is used to determine the index of the cleared LS1B.
 func PopBit(bb *uint64) int {
-	b := *bb ^ (*bb - 1)
-	fold := (b & 1) ^ (b >> 32)
+	sq := bits.TrailingZeros64(*bb)
 	*bb &= (*bb - 1)
 
-	return BitTable[(fold*0x783a9b23)>>26]
+	return sq
 }
 
 // CountBits counts the number of set (1) bits in a uint64 bitboard.
 func CountBits(b uint64) int {
-	count := 0
-	for b > 0 {
-		count += int(b & 1)
-		b >>= 1
-	}
-	return count
+	return bits.OnesCount64(b)
 }
 
 // InitBitMasks initializes the SetMask and ClearMask arrays to facilitate bit manipulation.
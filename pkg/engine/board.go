@@ -16,29 +16,81 @@ type Undo struct {
 	PosKey     uint64
 }
 
+// Position is the part of a board changed by MakeMove: the pieces and the state of the game, without the history of
+// the moves played. It is a plain value, so that copying it is cheap and safe. Saving it before a move and restoring it
+// afterwards (see Board.Save and Board.Restore) is the copy-make alternative to TakeMove.
+type Position struct {
+	Pieces     [BRD_SQ_NUM]int // Stores the pieces on the board at each square.
+	Pawns      [3]uint64       // Bitboards for pawns: white, black, and both.
+	Bitboards  [13]uint64      // Bitboards for every piece type.
+	Occupancy  [3]uint64       // Bitboards of occupied squares: white, black, and both.
+	KingSq     [2]int          // Squares of the kings for white and black.
+	Side       int             // Current side to move: 0 for white, 1 for black.
+	EnPassant  int             // En passant square.
+	FiftyMove  int             // Number of half-moves since the last pawn move or capture.
+	FullMove   int             // Full move number, incremented after every black move.
+	Ply        int             // Number of half-moves in the current search.
+	HisPly     int             // Total number of half-moves in the history.
+	PosKey     uint64          // Unique hash key of the current position.
+	PCENum     [13]int         // Number of each piece type on the board.
+	BigPCE     [2]int          // Number of big pieces (not pawns, the king included) for each side.
+	MajPCE     [2]int          // Number of major pieces (rooks and queens) for each side.
+	MinPCE     [2]int          // Number of minor pieces (knights and bishops) for each side.
+	Material   [2]int          // Material value of the position for each side.
+	CastlePerm int             // Castling permissions for both sides.
+	PList      [13][10]int     // Piece list for each piece type and each side.
+}
+
 // Board represents the state of a chessboard including piece positions, game status, and move history.
+// Besides the position, it holds the setup of the game (castling rooks, Chess960), the history of the moves played,
+// and the state of the search: the hash table and the move ordering tables.
 type Board struct {
-	Pieces        [BRD_SQ_NUM]int     // Stores the pieces on the board at each square.
-	Pawns         [3]uint64           // Bitboards for pawns: white, black, and both.
-	KingSq        [2]int              // Squares of the kings for white and black.
-	Side          int                 // Current side to move: 0 for white, 1 for black.
-	EnPassant     int                 // En passant square.
-	FiftyMove     int                 // Number of half-moves since the last pawn move or capture.
-	Ply           int                 // Number of half-moves in the current search.
-	HisPly        int                 // Total number of half-moves in the history.
-	PosKey        uint64              // Unique hash key of the current position.
-	PCENum        [13]int             // Number of each piece type on the board.
-	BigPCE        [2]int              // Number of big pieces (not pawns) for each side.
-	MajPCE        [2]int              // Number of major pieces (rooks and queens) for each side.
-	MinPCE        [2]int              // Number of minor pieces (knights and bishops) for each side.
-	Material      [2]int              // Material value of the position for each side.
-	CastlePerm    int                 // Castling permissions for both sides.
-	History       [MAXGAMESMOVES]Undo // History of moves.
-	PList         [13][10]int         // Piece list for each piece type and each side.
-	HashTable     HashTable           // Hash table for storing positions in the transposition table.
+	Position
+
+	CastleRooks [4]int              // Starting squares of the castling rooks, indexed by castling permission bit.
+	Chess960    bool                // Print and parse castling moves as Chess960 (king takes rook) moves.
+	History     [MAXGAMESMOVES]Undo // History of moves, up to HisPly.
+	Eval        *EvalParams         // Evaluation terms, DefaultEvalParams if nil. Shared by the search threads.
+
+	HashTable     *HashTable          // Hash table for storing positions in the transposition table, shared by the search threads.
 	PvArray       [MAXDEPTH]int       // Principal variation array for storing the best moves in the search.
 	SearchHistory [13][BRD_SQ_NUM]int // Search history table for move ordering heuristics.
 	SearchKillers [2][MAXDEPTH]int    // Search killer moves table for move ordering heuristics.
+
+	// Every time we move a piece, we will do CastlePerm &= CastlePermMask[from]
+	// and CastlePerm &= CastlePermMask[to]. The mask is 1111 == 15 for every square
+	// except for the squares of the kings and of the castling rooks (A1, E1, H1 & A8, E8, H8 in standard chess).
+	// When the king moves, it takes out the castle permissions for both sides of its color,
+	// eq. Black king moves from E8 to E7. CastlePerm &= 3 -> gives 0011 -> which means BLACK side lost castling permissions
+	// on both queen and king side. It is filled by InitCastleMasks.
+	CastlePermMask [BRD_SQ_NUM]int
+}
+
+// Clone returns a copy of the board for another goroutine: the position, the setup of the game and the moves played
+// to reach it (needed for repetitions and TakeMove). Only the used part of the history is copied, and none of the
+// search state: the clone has empty move ordering tables and no hash table. Set its HashTable to share the one of b,
+// as the search threads do, or call InitHashTable to give it its own.
+func (b *Board) Clone() *Board {
+	c := &Board{
+		Position:       b.Position,
+		CastleRooks:    b.CastleRooks,
+		Chess960:       b.Chess960,
+		Eval:           b.Eval,
+		CastlePermMask: b.CastlePermMask,
+	}
+	copy(c.History[:b.HisPly], b.History[:b.HisPly])
+
+	return c
+}
+
+// Save returns the position of the board, to be restored after making moves.
+func (b *Board) Save() Position {
+	return b.Position
+}
+
+// Restore takes back all the moves made since the position was saved by Save, in one copy.
+func (b *Board) Restore(p Position) {
+	b.Position = p
 }
 
 // PrintBoard prints the current state of the chessboard to the specified output writer.
@@ -93,6 +145,7 @@ func (b *Board) PrintBoard(out io.Writer) {
 	fmt.Fprintf(out, "\n")
 
 	fmt.Fprintf(out, "posKey: %X\n", b.PosKey)
+	fmt.Fprintf(out, "fen: %s\n", b.ToFen())
 }
 
 func InitSq120To64() {
@@ -117,8 +170,13 @@ func InitSq120To64() {
 	}
 }
 
+// DebugChecks enables the board consistency checks performed by CheckBoard.
+// It is read once from the DEBUG environment variable, since looking it up on every call
+// dominates the cost of move generation.
+var DebugChecks = os.Getenv("DEBUG") == "1"
+
 func (b *Board) CheckBoard() {
-	if os.Getenv("DEBUG") != "1" {
+	if !DebugChecks {
 		return
 	}
 	tempPceNum := [13]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
@@ -128,6 +186,8 @@ func (b *Board) CheckBoard() {
 	tempMaterial := [2]int{0, 0}
 
 	tempPawns := [3]uint64{0, 0, 0}
+	tempBitboards := [13]uint64{}
+	tempOccupancy := [3]uint64{0, 0, 0}
 
 	tempPawns[WHITE] = b.Pawns[WHITE]
 	tempPawns[BLACK] = b.Pawns[BLACK]
@@ -161,6 +221,9 @@ func (b *Board) CheckBoard() {
 
 		if tempPiece != EMPTY {
 			tempMaterial[color] += PieceVal[tempPiece]
+			SetBit(&tempBitboards[tempPiece], sq64)
+			SetBit(&tempOccupancy[color], sq64)
+			SetBit(&tempOccupancy[BOTH], sq64)
 		}
 	}
 
@@ -185,6 +248,16 @@ func (b *Board) CheckBoard() {
 		log.Fatalf("Pieces not aligned (5)")
 	}
 
+	for tempPiece := WP; tempPiece <= BK; tempPiece++ {
+		if tempBitboards[tempPiece] != b.Bitboards[tempPiece] {
+			log.Fatalf("Bitboards not aligned (%c)", PceChar[tempPiece])
+		}
+	}
+
+	if tempOccupancy != b.Occupancy {
+		log.Fatalf("Occupancy not aligned")
+	}
+
 }
 
 func (b *Board) ResetBoard() {
@@ -206,10 +279,12 @@ func (b *Board) ResetBoard() {
 
 	for i := 0; i < 3; i++ {
 		b.Pawns[i] = 0
+		b.Occupancy[i] = 0
 	}
 
 	for i := 0; i < 13; i++ {
 		b.PCENum[i] = 0
+		b.Bitboards[i] = 0
 	}
 
 	b.KingSq[WHITE] = NO_SQ
@@ -218,10 +293,12 @@ func (b *Board) ResetBoard() {
 	b.Side = BOTH
 	b.EnPassant = NO_SQ
 	b.FiftyMove = 0
+	b.FullMove = 1
 
 	b.Ply = 0
 	b.HisPly = 0
 	b.CastlePerm = 0
+	b.CastleRooks = StartCastleRooks
 	b.PosKey = 0
 }
 
@@ -260,6 +337,10 @@ func (b *Board) ClearPiece(sq int) error {
 	b.Pieces[sq] = EMPTY
 	b.Material[col] -= PieceVal[piece]
 
+	ClearBit(&b.Bitboards[piece], SQ64[sq])
+	ClearBit(&b.Occupancy[col], SQ64[sq])
+	ClearBit(&b.Occupancy[BOTH], SQ64[sq])
+
 	if PieceBig[piece] != 0 {
 		b.BigPCE[col]--
 		if PieceMaj[piece] != 0 {
@@ -317,6 +398,10 @@ func (b *Board) AddPiece(sq int, piece int) {
 	b.HashPiece(piece, sq)
 	b.Pieces[sq] = piece
 
+	SetBit(&b.Bitboards[piece], SQ64[sq])
+	SetBit(&b.Occupancy[col], SQ64[sq])
+	SetBit(&b.Occupancy[BOTH], SQ64[sq])
+
 	if PieceBig[piece] != 0 {
 		b.BigPCE[col]++
 		if PieceMaj[piece] != 0 {
@@ -348,6 +433,11 @@ func (b *Board) MovePiece(from, to int) {
 	b.HashPiece(piece, to)
 	b.Pieces[to] = piece
 
+	moveMask := SetMask[SQ64[from]] | SetMask[SQ64[to]]
+	b.Bitboards[piece] ^= moveMask
+	b.Occupancy[col] ^= moveMask
+	b.Occupancy[BOTH] ^= moveMask
+
 	if PieceBig[piece] == 0 {
 		ClearBit(&b.Pawns[col], SQ64[from])
 		ClearBit(&b.Pawns[BOTH], SQ64[from])
@@ -384,16 +474,7 @@ func (b *Board) MakeMove(move int) (int, error) {
 			b.ClearPiece(to + 10)
 		}
 	} else if move&MoveFlagCastle != 0 {
-		switch to {
-		case C1:
-			b.MovePiece(A1, D1)
-		case C8:
-			b.MovePiece(A8, D8)
-		case G1:
-			b.MovePiece(H1, F1)
-		case G8:
-			b.MovePiece(H8, F8)
-		default:
+		if PieceKing[b.Pieces[from]] == FALSE || b.Pieces[to] != SideRook[side] {
 			return 0, errors.New("illegal castle move")
 		}
 	}
@@ -408,8 +489,8 @@ func (b *Board) MakeMove(move int) (int, error) {
 	b.History[b.HisPly].EnPassant = b.EnPassant
 	b.History[b.HisPly].CastlePerm = b.CastlePerm
 
-	b.CastlePerm &= CastlePerm[from]
-	b.CastlePerm &= CastlePerm[to]
+	b.CastlePerm &= b.CastlePermMask[from]
+	b.CastlePerm &= b.CastlePermMask[to]
 	b.EnPassant = NO_SQ
 
 	b.HashCA()
@@ -450,20 +531,28 @@ func (b *Board) MakeMove(move int) (int, error) {
 		}
 	}
 
-	b.MovePiece(from, to)
+	if move&MoveFlagCastle != 0 {
+		b.castle(from, to)
+	} else {
+		b.MovePiece(from, to)
+
+		isPromotedPiece := GetPromoted(move)
+		if isPromotedPiece != EMPTY {
+			if !PieceValid(isPromotedPiece) || PiecePawn[isPromotedPiece] == TRUE {
+				return 0, errors.New("illegal piece promotion")
+			}
 
-	isPromotedPiece := GetPromoted(move)
-	if isPromotedPiece != EMPTY {
-		if !PieceValid(isPromotedPiece) || PiecePawn[isPromotedPiece] == TRUE {
-			return 0, errors.New("illegal piece promotion")
+			b.ClearPiece(to)
+			b.AddPiece(to, isPromotedPiece)
 		}
 
-		b.ClearPiece(to)
-		b.AddPiece(to, isPromotedPiece)
+		if PieceKing[b.Pieces[to]] != 0 {
+			b.KingSq[b.Side] = to
+		}
 	}
 
-	if PieceKing[b.Pieces[to]] != 0 {
-		b.KingSq[b.Side] = to
+	if side == BLACK {
+		b.FullMove++
 	}
 
 	b.Side ^= 1
@@ -542,25 +631,22 @@ func (b *Board) TakeMove() {
 	b.Side ^= 1
 	b.HashSide()
 
+	if b.Side == BLACK {
+		b.FullMove--
+	}
+
+	if MoveFlagCastle&move != 0 {
+		b.uncastle(from, to)
+		b.CheckBoard()
+		return
+	}
+
 	if MoveFlagEnPassant&move != 0 {
 		if b.Side == WHITE {
 			b.AddPiece(to-10, BP)
 		} else {
 			b.AddPiece(to+10, WP)
 		}
-	} else if MoveFlagCastle&move != 0 {
-		switch to {
-		case C1:
-			b.MovePiece(D1, A1)
-		case C8:
-			b.MovePiece(D8, A8)
-		case G1:
-			b.MovePiece(F1, H1)
-		case G8:
-			b.MovePiece(F8, H8)
-		default:
-			panic("illegal take move")
-		}
 	}
 
 	b.MovePiece(to, from)
@@ -620,8 +706,14 @@ func (b *Board) TakeNullMove() {
 }
 
 // from the last time the FiftyMove was set to zero, loop over and check for repetition
+// (the FiftyMove counter can be larger than the history when the position was set up from a FEN)
 func (b *Board) IsRepetition() bool {
-	for i := b.HisPly - b.FiftyMove; i < b.HisPly-1; i++ {
+	start := b.HisPly - b.FiftyMove
+	if start < 0 {
+		start = 0
+	}
+
+	for i := start; i < b.HisPly-1; i++ {
 		if b.PosKey == b.History[i].PosKey {
 			return true
 		}
@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+/*
+Polyglot is the most widely used opening book format. A book is a file of 16 bytes entries, sorted by key and stored big-endian:
+
+	key    uint64 -> Polyglot key of the position (see PolyglotKey)
+	move   uint16 -> bits 0-2: to file, 3-5: to rank, 6-8: from file, 9-11: from rank, 12-14: promotion (1=N, 2=B, 3=R, 4=Q)
+	weight uint16 -> how often the move should be played, relative to the other moves of the position
+	learn  uint32 -> learning data, unused
+
+Castling moves are encoded as the king taking its own rook (e1h1), which is also how the engine encodes them.
+*/
+
+// BookEntry is an entry of a Polyglot opening book.
+type BookEntry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+// Book is a Polyglot opening book.
+type Book struct {
+	Entries []BookEntry // Sorted by key.
+}
+
+// LoadBook reads a Polyglot opening book from a .bin file.
+func LoadBook(path string) (*Book, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadBook(file)
+}
+
+// ReadBook reads a Polyglot opening book. The entries are sorted by key if they are not already.
+func ReadBook(r io.Reader) (*Book, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%16 != 0 {
+		return nil, fmt.Errorf("invalid polyglot book: size %d is not a multiple of 16", len(data))
+	}
+
+	book := &Book{Entries: make([]BookEntry, len(data)/16)}
+	for i := range book.Entries {
+		entry := data[i*16 : i*16+16]
+		book.Entries[i] = BookEntry{
+			Key:    binary.BigEndian.Uint64(entry[0:8]),
+			Move:   binary.BigEndian.Uint16(entry[8:10]),
+			Weight: binary.BigEndian.Uint16(entry[10:12]),
+			Learn:  binary.BigEndian.Uint32(entry[12:16]),
+		}
+	}
+
+	if !sort.SliceIsSorted(book.Entries, func(i, j int) bool { return book.Entries[i].Key < book.Entries[j].Key }) {
+		sort.SliceStable(book.Entries, func(i, j int) bool { return book.Entries[i].Key < book.Entries[j].Key })
+	}
+
+	return book, nil
+}
+
+// SetBookFile loads the Polyglot opening book used by the search. An empty path or "<empty>" unloads the book.
+// The previous book is kept if the new one can not be loaded.
+func (s *SearchInfo) SetBookFile(path string) error {
+	if path == "" || path == "<empty>" {
+		s.Book = nil
+		return nil
+	}
+
+	book, err := LoadBook(path)
+	if err != nil {
+		return err
+	}
+	s.Book = book
+
+	return nil
+}
+
+// Write writes the book in Polyglot format. The entries must be sorted by key.
+func (bk *Book) Write(w io.Writer) error {
+	var entry [16]byte
+
+	for _, e := range bk.Entries {
+		binary.BigEndian.PutUint64(entry[0:8], e.Key)
+		binary.BigEndian.PutUint16(entry[8:10], e.Move)
+		binary.BigEndian.PutUint16(entry[10:12], e.Weight)
+		binary.BigEndian.PutUint32(entry[12:16], e.Learn)
+
+		if _, err := w.Write(entry[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the entries of the position with the given Polyglot key.
+func (bk *Book) Lookup(key uint64) []BookEntry {
+	i := sort.Search(len(bk.Entries), func(i int) bool { return bk.Entries[i].Key >= key })
+
+	j := i
+	for j < len(bk.Entries) && bk.Entries[j].Key == key {
+		j++
+	}
+
+	return bk.Entries[i:j]
+}
+
+// BookMoves returns the legal book moves of the position along with their weights.
+// Book entries that do not match a legal move (e.g. because of a key collision) are skipped.
+func (bk *Book) BookMoves(b *Board) ([]int, []int) {
+	var moves, weights []int
+
+	for _, e := range bk.Lookup(PolyglotKey(b)) {
+		if move := ParseBookMove(e.Move, b); move != NOMOVE {
+			moves = append(moves, move)
+			weights = append(weights, int(e.Weight))
+		}
+	}
+
+	return moves, weights
+}
+
+// PickMove returns a book move for the position, picked at random with a probability proportional to its weight,
+// or NOMOVE if the position is not in the book.
+func (bk *Book) PickMove(b *Board) int {
+	moves, weights := bk.BookMoves(b)
+
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		// moves with a weight of 0 are only played if nothing else is known about the position
+		if len(moves) == 0 {
+			return NOMOVE
+		}
+		return moves[rand.Intn(len(moves))]
+	}
+
+	r := rand.Intn(total)
+	for i, w := range weights {
+		if r < w {
+			return moves[i]
+		}
+		r -= w
+	}
+
+	return NOMOVE
+}
+
+// polyglotPieceKind maps the pieces to the piece kinds of the Polyglot keys.
+var polyglotPieceKind = [13]int{-1, 1, 3, 5, 7, 9, 11, 0, 2, 4, 6, 8, 10}
+
+// PolyglotKey returns the Polyglot key of the position, used to look it up in Polyglot opening books.
+// It is computed like the position key (GeneratePosKey), but with the fixed numbers of the Polyglot format.
+// The en passant file is only part of the key when a pawn of the side to move can actually capture en passant.
+func PolyglotKey(b *Board) uint64 {
+	var key uint64
+
+	for piece := WP; piece <= BK; piece++ {
+		bb := b.Bitboards[piece]
+		for bb != 0 {
+			sq := PopBit(&bb)
+			key ^= PolyglotRandom64[64*polyglotPieceKind[piece]+sq]
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		if b.CastlePerm&(1<<i) != 0 {
+			key ^= PolyglotRandom64[768+i]
+		}
+	}
+
+	if b.EnPassant != NO_SQ && PawnAttacks[b.Side^1][SQ64[b.EnPassant]]&b.Bitboards[SidePawn[b.Side]] != 0 {
+		key ^= PolyglotRandom64[772+FilesBrd[b.EnPassant]]
+	}
+
+	if b.Side == WHITE {
+		key ^= PolyglotRandom64[780]
+	}
+
+	return key
+}
+
+// polyglotPromotion maps the promotion field of a Polyglot move to the promoted piece type of the white side.
+var polyglotPromotion = [8]int{EMPTY, WN, WB, WR, WQ, EMPTY, EMPTY, EMPTY}
+
+// ParseBookMove converts a move of a Polyglot book to the matching legal move of the board, or NOMOVE.
+func ParseBookMove(bookMove uint16, b *Board) int {
+	to := FR2SQ(int(bookMove&7), int(bookMove>>3&7))
+	from := FR2SQ(int(bookMove>>6&7), int(bookMove>>9&7))
+	promoted := polyglotPromotion[bookMove>>12&7]
+
+	for _, move := range LegalMoves(b) {
+		if GetFrom(move) != from || GetToSq(move) != to {
+			continue
+		}
+
+		p := GetPromoted(move)
+		if p != EMPTY && PieceCol[p] == BLACK {
+			p -= BP - WP
+		}
+		if p == promoted {
+			return move
+		}
+	}
+
+	return NOMOVE
+}
+
+// BookMove converts a move to its Polyglot book encoding.
+func BookMove(move int) uint16 {
+	from := SQ64[GetFrom(move)]
+	to := SQ64[GetToSq(move)]
+
+	m := uint16(to%8) | uint16(to/8)<<3 | uint16(from%8)<<6 | uint16(from/8)<<9
+
+	if p := GetPromoted(move); p != EMPTY {
+		if PieceCol[p] == BLACK {
+			p -= BP - WP
+		}
+		for i, promoted := range polyglotPromotion {
+			if promoted == p && i > 0 {
+				m |= uint16(i) << 12
+			}
+		}
+	}
+
+	return m
+}
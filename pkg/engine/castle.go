@@ -0,0 +1,216 @@
+package engine
+
+/*
+Castling is handled the same way for standard chess and Chess960 (Fischer Random).
+
+Every castling right (WKCA, WQCA, BKCA, BQCA) remembers the starting square of its rook in Board.CastleRooks,
+indexed by the position of the permission bit (0 for WKCA, 1 for WQCA, 2 for BKCA and 3 for BQCA).
+A castling move is encoded as the king "capturing" its own rook: from is the king square, to is the rook square
+and the MoveFlagCastle flag is set. Whatever the starting files are, after castling the king and the rook end up on
+the same squares as in standard chess: g1/f1 (king side) and c1/d1 (queen side), or the same files on rank 8.
+
+For standard positions this gives e1h1 / e1a1 internally, which is printed as e1g1 / e1c1 unless the board is in
+Chess960 mode (see FormatMove).
+*/
+
+// StartCastleRooks are the rook squares of the castling rights in standard chess.
+var StartCastleRooks = [4]int{H1, A1, H8, A8}
+
+// CastleBits holds the castling permission bits of each side: king side first, then queen side.
+var CastleBits = [2][2]int{{WKCA, WQCA}, {BKCA, BQCA}}
+
+// CastleSquares returns the destination squares of the king and of the rook for a castling move,
+// given the square of the king and the square of the castling rook.
+func CastleSquares(king, rook int) (int, int) {
+	rank := RanksBrd[king]
+	if FilesBrd[rook] > FilesBrd[king] {
+		return FR2SQ(FILE_G, rank), FR2SQ(FILE_F, rank)
+	}
+
+	return FR2SQ(FILE_C, rank), FR2SQ(FILE_D, rank)
+}
+
+// spanMask returns a bitboard of the squares from a to b (both included). Both squares must be on the same rank.
+func spanMask(a, b int) uint64 {
+	if a > b {
+		a, b = b, a
+	}
+
+	var mask uint64
+	for sq := a; sq <= b; sq++ {
+		mask |= SetMask[SQ64[sq]]
+	}
+
+	return mask
+}
+
+// AddCastleRight grants a castling right from a FEN castling character. Both the classic KQkq letters
+// (X-FEN, the outermost rook on that side of the king) and the Shredder-FEN file letters (A-H, a-h) are supported.
+// It returns false if there is no king and matching rook on the back rank.
+func (b *Board) AddCastleRight(c byte) bool {
+	index, rook, ok := findCastleRook(&b.Pieces, c)
+	if !ok {
+		return false
+	}
+
+	b.CastlePerm |= 1 << index
+	b.CastleRooks[index] = rook
+
+	return true
+}
+
+// findCastleRook resolves a FEN castling character against the given pieces, returning the index of the castling
+// right (the position of its permission bit) and the square of its rook.
+func findCastleRook(pieces *[BRD_SQ_NUM]int, c byte) (int, int, bool) {
+	side := WHITE
+	rank := RANK_1
+	if c >= 'a' && c <= 'z' {
+		side = BLACK
+		rank = RANK_8
+		c -= 'a' - 'A'
+	}
+
+	kingFile := FILE_NONE
+	for file := FILE_A; file <= FILE_H; file++ {
+		if pieces[FR2SQ(file, rank)] == SideKing[side] {
+			kingFile = file
+		}
+	}
+	if kingFile == FILE_NONE {
+		return 0, NO_SQ, false
+	}
+
+	rookFile := FILE_NONE
+	switch {
+	case c == 'K':
+		for file := FILE_H; file > kingFile && rookFile == FILE_NONE; file-- {
+			if pieces[FR2SQ(file, rank)] == SideRook[side] {
+				rookFile = file
+			}
+		}
+	case c == 'Q':
+		for file := FILE_A; file < kingFile && rookFile == FILE_NONE; file++ {
+			if pieces[FR2SQ(file, rank)] == SideRook[side] {
+				rookFile = file
+			}
+		}
+	case c >= 'A' && c <= 'H':
+		if pieces[FR2SQ(int(c-'A'), rank)] == SideRook[side] {
+			rookFile = int(c - 'A')
+		}
+	}
+	if rookFile == FILE_NONE || rookFile == kingFile {
+		return 0, NO_SQ, false
+	}
+
+	index := side * 2
+	if rookFile < kingFile {
+		index++
+	}
+
+	return index, FR2SQ(rookFile, rank), true
+}
+
+// InitCastleMasks fills CastlePermMask from the current castling rights, the castling rooks and the kings.
+// Moving a king clears both castling rights of its side, and moving (or capturing) a castling rook clears its right.
+func (b *Board) InitCastleMasks() {
+	for sq := 0; sq < BRD_SQ_NUM; sq++ {
+		b.CastlePermMask[sq] = 15
+	}
+
+	for index := 0; index < 4; index++ {
+		if b.CastlePerm&(1<<index) == 0 {
+			continue
+		}
+
+		side := index / 2
+		b.CastlePermMask[b.CastleRooks[index]] &^= 1 << index
+		b.CastlePermMask[b.KingSq[side]] &^= CastleBits[side][0] | CastleBits[side][1]
+	}
+}
+
+// generateCastleMoves adds the castling moves of the side to move.
+// Every square the king and the rook travel over must be empty (apart from the king and the rook themselves),
+// and the king must not be in check, pass over or land on an attacked square. The king square is always checked,
+// even when the king does not move: otherwise the rook could castle into a square blocking a check.
+func (ml *MoveList) generateCastleMoves(b *Board) {
+	side := b.Side
+	king := b.KingSq[side]
+
+	for wing := 0; wing < 2; wing++ {
+		index := side*2 + wing
+		if b.CastlePerm&(1<<index) == 0 {
+			continue
+		}
+
+		rook := b.CastleRooks[index]
+		kingTo, rookTo := CastleSquares(king, rook)
+
+		occ := b.Occupancy[BOTH] &^ (SetMask[SQ64[king]] | SetMask[SQ64[rook]])
+		if (spanMask(king, kingTo)|spanMask(rook, rookTo))&occ != 0 {
+			continue
+		}
+
+		attacked := false
+		path := spanMask(king, kingTo)
+		for path != 0 && !attacked {
+			attacked = SqAttacked(SQ120[PopBit(&path)], side^1, b) == TRUE
+		}
+
+		if !attacked {
+			ml.AddQuietMove(b, NewMove(king, rook, EMPTY, EMPTY, MoveFlagCastle))
+		}
+	}
+}
+
+// castle moves the king and the rook of a castling move. The rook is lifted first, since in Chess960
+// the king may land on the square of the rook (or the rook on the square of the king).
+func (b *Board) castle(king, rook int) {
+	kingTo, rookTo := CastleSquares(king, rook)
+	piece := b.Pieces[rook]
+
+	b.ClearPiece(rook)
+	if king != kingTo {
+		b.MovePiece(king, kingTo)
+	}
+	b.AddPiece(rookTo, piece)
+
+	b.KingSq[PieceCol[piece]] = kingTo
+}
+
+// uncastle takes back a castling move made with castle.
+func (b *Board) uncastle(king, rook int) {
+	kingTo, rookTo := CastleSquares(king, rook)
+	piece := b.Pieces[rookTo]
+
+	b.ClearPiece(rookTo)
+	if king != kingTo {
+		b.MovePiece(kingTo, king)
+	}
+	b.AddPiece(rook, piece)
+
+	b.KingSq[PieceCol[piece]] = king
+}
+
+// FindCastleMove returns the legal castling move of the side to move on the given wing, or NOMOVE.
+func FindCastleMove(b *Board, kingSide bool) int {
+	var ml MoveList
+	GenerateAllMoves(b, &ml)
+
+	for i := 0; i < ml.Count; i++ {
+		move := ml.Moves[i].Move
+		if move&MoveFlagCastle == 0 || (FilesBrd[GetToSq(move)] > FilesBrd[GetFrom(move)]) != kingSide {
+			continue
+		}
+
+		res, err := b.MakeMove(move)
+		if err != nil || res == FALSE {
+			continue
+		}
+		b.TakeMove()
+
+		return move
+	}
+
+	return NOMOVE
+}
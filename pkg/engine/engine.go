@@ -1,5 +1,7 @@
 package engine
 
+import "sync"
+
 // SQ64 is an array that maps 120-square board indices to 64-square board indices.
 // It allows for efficient conversion between the 120-square and 64-square representations of the board.
 var SQ64 [BRD_SQ_NUM]int
@@ -71,71 +73,6 @@ var PieceBishopQueen = [13]int{FALSE, FALSE, FALSE, TRUE, FALSE, TRUE, FALSE, FA
 // PieceSlides is a boolean array that indicates whether a piece type can slide across the board (1) or not (0).
 var PieceSlides = [13]int{FALSE, FALSE, FALSE, TRUE, TRUE, TRUE, FALSE, FALSE, FALSE, TRUE, TRUE, TRUE, FALSE}
 
-// used for movegen
-var LoopSlidePieces = [8]int{WB, WR, WQ, 0, BB, BR, BQ, 0}
-var LoopSlideIndex = [2]int{0, 4}
-var LoopNonSlidePieces = [6]int{WN, WK, 0, BN, BK, 0}
-var LoopNonSlideIndex = [2]int{0, 3}
-
-// PieceDir is a two-dimensional array that stores the possible directions each piece type can move on a chessboard.
-// It is used for move generation, helping to determine the valid move directions for each piece.
-//
-// Explanation of Values:
-// - The array has 13 rows, each corresponding to a piece type (including empty squares), and 8 columns representing different directions.
-// - Values in the array represent the relative positions a piece can move in a given direction.
-// - For example, PieceDir[WN][0] represents the first direction a white knight can move, which is two squares up and one square left.
-//
-// Usage:
-//   - This array is essential for generating legal moves for pieces, allowing the engine to identify valid move directions
-//     and calculate potential destination squares.
-//
-// Note: The values in this array are relative positions on the board, and their interpretation may vary depending on the piece type.
-var PieceDir = [13][8]int{
-	{0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 0, 0, 0, 0},
-	{-8, -19, -21, -12, 8, 19, 21, 12},
-	{-9, -11, 11, 9, 0, 0, 0, 0},
-	{-1, -10, 1, 10, 0, 0, 0, 0},
-	{-1, -10, 1, 10, -9, -11, 11, 9},
-	{-1, -10, 1, 10, -9, -11, 11, 9},
-	{0, 0, 0, 0, 0, 0, 0, 0},
-	{-8, -19, -21, -12, 8, 19, 21, 12},
-	{-9, -11, 11, 9, 0, 0, 0, 0},
-	{-1, -10, 1, 10, 0, 0, 0, 0},
-	{-1, -10, 1, 10, -9, -11, 11, 9},
-	{-1, -10, 1, 10, -9, -11, 11, 9},
-}
-
-// NumDir is an array that represents the number of possible directions a piece can move for each piece type.
-// It is utilized in the move generation function to determine the valid directions for generating moves for each piece.
-//
-// Explanation of Values:
-// - The values in the array correspond to piece types, with indices ranging from 0 (unused) to 12 (for all piece types, including empty squares).
-// - For example, NumDir[WN] indicates the number of valid directions a white knight can move.
-// - The array helps control the generation of legal moves by specifying the number of directions to consider for each piece.
-var NumDir = [13]int{0, 0, 8, 4, 4, 8, 8, 0, 8, 4, 4, 8, 8}
-
-// Every time we move a piece, we will do castle_permissions &= CastlePerm[from]
-// and castle_permissions &= CastlePerm[from]. The result of these operations is 1111 == 15
-// except for A1, E1, H1 & A8, E8, H8
-// When the rooks of the queen moves on either side, it takes out the castle permissions for that side
-// eq. Black queen moves from E8 to E7. castle_permissions &= 3 -> gives 0011 -> which means BLACK side lost castling permissions
-// on both queen and king side.
-var CastlePerm = [120]int{
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 13, 15, 15, 15, 12, 15, 15, 14, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 7, 15, 15, 15, 3, 15, 15, 11, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-	15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
-}
-
 var FEN0 = "8/3q4/8/8/4Q3/8/8/8 w - - 0 2"
 var FEN1 = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 var FEN2 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
@@ -154,13 +91,21 @@ var PERFTFEN = "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -
 
 var START_FEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 
+var initOnce sync.Once
+
+// InitAll fills the lookup tables of the engine. The tables are only filled by the first call, so it can be called
+// by every user of the package, from any goroutine.
 func InitAll() {
-	InitSq120To64()
-	InitBitMasks()
-	InitHashKeys()
-	InitFilesRanksBrd()
-	InitEvalMasks()
-	InitMvvLva()
+	initOnce.Do(func() {
+		InitSq120To64()
+		InitBitMasks()
+		InitAttacks()
+		InitHashKeys()
+		InitFilesRanksBrd()
+		InitEvalMasks()
+		InitMvvLva()
+		InitReductions()
+	})
 }
 
 func InitEvalMasks() {
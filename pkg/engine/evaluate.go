@@ -2,13 +2,28 @@ package engine
 
 import "math"
 
-var PawnIsolated = -10
+// EvalParams are the evaluation terms that can be changed through the options, in centipawns.
+// Every board points to its own (see Board.Eval), so that engines with different settings can run side by side.
+type EvalParams struct {
+	PawnIsolated      int
+	RookOpenFile      int
+	RookSemiOpenFile  int
+	QueenOpenFile     int
+	QueenSemiOpenFile int
+	BishopPair        int
+}
+
+// DefaultEvalParams are the evaluation terms of the boards that have none set.
+var DefaultEvalParams = EvalParams{
+	PawnIsolated:      -10,
+	RookOpenFile:      10,
+	RookSemiOpenFile:  5,
+	QueenOpenFile:     5,
+	QueenSemiOpenFile: 3,
+	BishopPair:        30,
+}
+
 var PawnPassed = [8]int{0, 5, 10, 20, 35, 60, 100, 200}
-var RookOpenFile = 10
-var RookSemiOpenFile = 5
-var QueenOpenFile = 5
-var QueenSemiOpenFile = 3
-var BishopPair = 30
 
 // used to switch between KingO for openings and KingE for endgames
 var EndGameMaterial = 1*PieceVal[WR] + 2*PieceVal[WN] + 2*PieceVal[WP]
@@ -94,7 +109,17 @@ var Mirror64 = [64]int{
 	0, 1, 2, 3, 4, 5, 6, 7,
 }
 
+// evalParams returns the evaluation terms of the board.
+func (b *Board) evalParams() *EvalParams {
+	if b.Eval == nil {
+		return &DefaultEvalParams
+	}
+
+	return b.Eval
+}
+
 func EvalPosition(b *Board) int {
+	p := b.evalParams()
 	score := b.Material[WHITE] - b.Material[BLACK]
 
 	if b.PCENum[WP] == 0 && b.PCENum[BP] == 0 && MaterialDraw(b) == TRUE {
@@ -107,7 +132,7 @@ func EvalPosition(b *Board) int {
 		score += PawnTable[SQ64[sq]]
 
 		if IsolatedMask[SQ64[sq]]&b.Pawns[WHITE] == 0 {
-			score += PawnIsolated
+			score += p.PawnIsolated
 		}
 
 		if WhitePassedMask[SQ64[sq]]&b.Pawns[BLACK] == 0 {
@@ -121,7 +146,7 @@ func EvalPosition(b *Board) int {
 		score -= PawnTable[Mirror64[SQ64[sq]]]
 
 		if IsolatedMask[SQ64[sq]]&b.Pawns[BLACK] == 0 {
-			score -= PawnIsolated
+			score -= p.PawnIsolated
 		}
 
 		if BlackPassedMask[SQ64[sq]]&b.Pawns[WHITE] == 0 {
@@ -159,9 +184,9 @@ func EvalPosition(b *Board) int {
 		score += RookTable[SQ64[sq]]
 
 		if b.Pawns[BOTH]&FileBBMask[FilesBrd[sq]] == 0 {
-			score += RookOpenFile
+			score += p.RookOpenFile
 		} else if b.Pawns[WHITE]&FileBBMask[FilesBrd[sq]] == 0 {
-			score += RookSemiOpenFile
+			score += p.RookSemiOpenFile
 		}
 	}
 
@@ -171,9 +196,9 @@ func EvalPosition(b *Board) int {
 		score -= RookTable[Mirror64[SQ64[sq]]]
 
 		if b.Pawns[BOTH]&FileBBMask[FilesBrd[sq]] == 0 {
-			score -= RookOpenFile
+			score -= p.RookOpenFile
 		} else if b.Pawns[BLACK]&FileBBMask[FilesBrd[sq]] == 0 {
-			score -= RookSemiOpenFile
+			score -= p.RookSemiOpenFile
 		}
 	}
 
@@ -181,9 +206,9 @@ func EvalPosition(b *Board) int {
 	for i := 0; i < b.PCENum[piece]; i++ {
 		sq := b.PList[piece][i]
 		if b.Pawns[BOTH]&FileBBMask[FilesBrd[sq]] == 0 {
-			score += QueenOpenFile
+			score += p.QueenOpenFile
 		} else if b.Pawns[WHITE]&FileBBMask[FilesBrd[sq]] == 0 {
-			score += QueenSemiOpenFile
+			score += p.QueenSemiOpenFile
 		}
 	}
 
@@ -191,9 +216,9 @@ func EvalPosition(b *Board) int {
 	for i := 0; i < b.PCENum[piece]; i++ {
 		sq := b.PList[piece][i]
 		if b.Pawns[BOTH]&FileBBMask[FilesBrd[sq]] == 0 {
-			score -= QueenOpenFile
+			score -= p.QueenOpenFile
 		} else if b.Pawns[BLACK]&FileBBMask[FilesBrd[sq]] == 0 {
-			score -= QueenSemiOpenFile
+			score -= p.QueenSemiOpenFile
 		}
 	}
 
@@ -214,10 +239,10 @@ func EvalPosition(b *Board) int {
 	}
 
 	if b.PCENum[WB] >= 2 {
-		score += BishopPair
+		score += p.BishopPair
 	}
 	if b.PCENum[BB] >= 2 {
-		score -= BishopPair
+		score -= p.BishopPair
 	}
 
 	if b.Side == WHITE {
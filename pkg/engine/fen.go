@@ -1,5 +1,11 @@
 package engine
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // ParseFen parses a FEN (Forsyth-Edwards Notation) string and sets up the board
 // according to the specified position.
 //
@@ -11,116 +17,306 @@ package engine
 // Parameters:
 //   - fen: A string containing the FEN representation of the chess position.
 //
-// The method iterates through the FEN string, interpreting its components, and sets
-// up the board accordingly. It also computes the position key (Zobrist key) and
-// updates various lists and material counts based on the position.
+// The whole string is validated before the board is touched: the piece placement must
+// describe 8 ranks of 8 squares with exactly one king per side and no pawns on the first
+// or last rank, castling rights must match a king and rook on the back rank, the en passant
+// square must be behind a pawn that just made a double step, the clocks must be
+// non-negative numbers and the side that is not to move must not be in check.
+// The half-move clock and the full move number are optional.
+//
+// If the FEN is invalid an error is returned and the board is left unchanged. Otherwise the
+// position key (Zobrist key), piece lists and material counts are computed for the position.
 //
 // Example usage:
-//   var board Board
-//   board.ParseFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
-//   // The board is now set up with the specified position.
 //
-// Note: The input FEN string should be correctly formatted and represent a valid
-// chess position.
-func (b *Board) ParseFen(fen string) {
-	rank := RANK_8
-	file := FILE_A
-	piece := 0
-	count := 0
-	sq64 := 0
-	sq120 := 0
-	fenPos := 0
+//	var board Board
+//	if err := board.ParseFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"); err != nil {
+//		// handle the error
+//	}
+//	// The board is now set up with the specified position.
+func (b *Board) ParseFen(fen string) error {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 || len(fields) > 6 {
+		return fmt.Errorf("invalid fen %q: expected 4 to 6 fields, got %d", fen, len(fields))
+	}
+
+	var pieces [BRD_SQ_NUM]int
+	var bitboards [13]uint64
+	var counts [13]int
+
+	for i := range pieces {
+		pieces[i] = OFFBOARD
+	}
+	for sq := 0; sq < 64; sq++ {
+		pieces[SQ120[sq]] = EMPTY
+	}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return fmt.Errorf("invalid fen %q: expected 8 ranks, got %d", fen, len(ranks))
+	}
+
+	for i, rankStr := range ranks {
+		rank := RANK_8 - i
+		file := FILE_A
+
+		for _, p := range rankStr {
+			if p >= '1' && p <= '8' {
+				file += int(p - '0')
+				if file > FILE_H+1 {
+					return fmt.Errorf("invalid fen %q: rank %d has more than 8 squares", fen, rank+1)
+				}
+				continue
+			}
+
+			piece := strings.IndexRune(PceChar, p)
+			if piece <= EMPTY {
+				return fmt.Errorf("invalid fen %q: unexpected character %q in piece placement", fen, p)
+			}
+			if file > FILE_H {
+				return fmt.Errorf("invalid fen %q: rank %d has more than 8 squares", fen, rank+1)
+			}
+			if PiecePawn[piece] == TRUE && (rank == RANK_1 || rank == RANK_8) {
+				return fmt.Errorf("invalid fen %q: pawn on rank %d", fen, rank+1)
+			}
+
+			counts[piece]++
+			if counts[piece] > len(b.PList[piece]) {
+				return fmt.Errorf("invalid fen %q: too many %c pieces", fen, p)
+			}
+
+			pieces[FR2SQ(file, rank)] = piece
+			bitboards[piece] |= 1 << (rank*8 + file)
+			file++
+		}
+
+		if file != FILE_H+1 {
+			return fmt.Errorf("invalid fen %q: rank %d has %d squares", fen, rank+1, file)
+		}
+	}
+
+	if counts[WK] != 1 || counts[BK] != 1 {
+		return fmt.Errorf("invalid fen %q: each side must have exactly one king", fen)
+	}
+
+	var side int
+	switch fields[1] {
+	case "w":
+		side = WHITE
+	case "b":
+		side = BLACK
+	default:
+		return fmt.Errorf("invalid fen %q: side to move must be w or b, got %q", fen, fields[1])
+	}
+
+	var occ uint64
+	for piece := WP; piece <= BK; piece++ {
+		occ |= bitboards[piece]
+	}
+	theirKing := SQ64[kingSquare(&pieces, SideKing[side^1])]
+	if AttackersOf(theirKing, side, occ, &bitboards) != 0 {
+		return fmt.Errorf("invalid fen %q: the side not to move is in check", fen)
+	}
+
+	// castling rights can be given as KQkq (X-FEN) or as the files of the rooks (Shredder-FEN, eq. HAha)
+	castling := fields[2]
+	if castling != "-" {
+		seen := 0
+		for i := 0; i < len(castling); i++ {
+			index, _, ok := findCastleRook(&pieces, castling[i])
+			if !ok {
+				return fmt.Errorf("invalid fen %q: castling right %q without king and rook", fen, castling[i])
+			}
+			if seen&(1<<index) != 0 {
+				return fmt.Errorf("invalid fen %q: duplicate castling right %q", fen, castling[i])
+			}
+			seen |= 1 << index
+		}
+	}
+
+	enPassant := NO_SQ
+	if fields[3] != "-" {
+		ep := fields[3]
+		if len(ep) != 2 || ep[0] < 'a' || ep[0] > 'h' || ep[1] < '1' || ep[1] > '8' {
+			return fmt.Errorf("invalid fen %q: bad en passant square %q", fen, ep)
+		}
+
+		file, rank := int(ep[0]-'a'), int(ep[1]-'1')
+		// the pawn that just made a double step stands in front of the square, coming from behind it
+		pawnRank, fromRank, pawn := RANK_5, RANK_7, BP
+		if side == BLACK {
+			pawnRank, fromRank, pawn = RANK_4, RANK_2, WP
+		}
+		if rank != (pawnRank+fromRank)/2 {
+			return fmt.Errorf("invalid fen %q: en passant square %q on the wrong rank", fen, ep)
+		}
+		if pieces[FR2SQ(file, rank)] != EMPTY || pieces[FR2SQ(file, fromRank)] != EMPTY ||
+			pieces[FR2SQ(file, pawnRank)] != pawn {
+			return fmt.Errorf("invalid fen %q: no pawn can be captured en passant on %q", fen, ep)
+		}
+
+		enPassant = FR2SQ(file, rank)
+	}
+
+	fiftyMove, fullMove := 0, 1
+	if len(fields) > 4 {
+		n, err := strconv.Atoi(fields[4])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid fen %q: bad half-move clock %q", fen, fields[4])
+		}
+		fiftyMove = n
+	}
+	if len(fields) > 5 {
+		n, err := strconv.Atoi(fields[5])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid fen %q: bad full move number %q", fen, fields[5])
+		}
+		// some tools write 0 for the first move
+		if n > 0 {
+			fullMove = n
+		}
+	}
 
 	b.ResetBoard()
 
-	for i, p := range fen {
-		if rank < RANK_1 {
-			break
+	for sq := 0; sq < BRD_SQ_NUM; sq++ {
+		if pieces[sq] != OFFBOARD && pieces[sq] != EMPTY {
+			b.Pieces[sq] = pieces[sq]
 		}
-		fenPos = i
-		count = 1
-		switch p {
-		case 'p':
-			piece = BP
-		case 'r':
-			piece = BR
-		case 'n':
-			piece = BN
-		case 'b':
-			piece = BB
-		case 'k':
-			piece = BK
-		case 'q':
-			piece = BQ
-		case 'P':
-			piece = WP
-		case 'R':
-			piece = WR
-		case 'N':
-			piece = WN
-		case 'B':
-			piece = WB
-		case 'K':
-			piece = WK
-		case 'Q':
-			piece = WQ
-		case '1', '2', '3', '4', '5', '6', '7', '8':
-			piece = EMPTY
-			count = int(p - '0')
-		case '/', ' ':
-			rank--
-			file = FILE_A
-			fenPos++
-			continue
-		default:
-			panic("FEN error")
+	}
+
+	b.Side = side
+	if castling != "-" {
+		for i := 0; i < len(castling); i++ {
+			b.AddCastleRight(castling[i])
+		}
+	}
+	b.EnPassant = enPassant
+	b.FiftyMove = fiftyMove
+	b.FullMove = fullMove
+
+	b.PosKey = GeneratePosKey(b)
+	UpdateListsMaterial(b)
+	b.InitCastleMasks()
+
+	return nil
+}
+
+// kingSquare returns the square of the given king in pieces, or NO_SQ if there is none.
+func kingSquare(pieces *[BRD_SQ_NUM]int, king int) int {
+	for sq := 0; sq < BRD_SQ_NUM; sq++ {
+		if pieces[sq] == king {
+			return sq
 		}
+	}
+
+	return NO_SQ
+}
+
+// ToFen returns the FEN (Forsyth-Edwards Notation) string of the current position.
+//
+// The output round-trips through ParseFen: piece placement, side to move, castling rights,
+// en passant square, half-move clock (FiftyMove) and full move number are all included.
+// Castling rights are written as KQkq, using the file of the rook (X-FEN) only when the
+// castling rook is not the outermost rook on its side of the king. In Chess960 mode the files
+// of the rooks are always used (Shredder-FEN, eq. HAha).
+//
+// Example usage:
+//
+//	var board Board
+//	board.ParseFen(START_FEN)
+//	fen := board.ToFen() // "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+func (b *Board) ToFen() string {
+	var sb strings.Builder
 
-		for i := 0; i < count; i++ {
-			sq64 = rank*8 + file
-			sq120 = SQ120[sq64]
-			if piece != EMPTY {
-				b.Pieces[sq120] = piece
+	for rank := RANK_8; rank >= RANK_1; rank-- {
+		empty := 0
+		for file := FILE_A; file <= FILE_H; file++ {
+			piece := b.Pieces[FR2SQ(file, rank)]
+			if piece == EMPTY {
+				empty++
+				continue
 			}
-			file++
+
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(PceChar[piece])
+		}
+
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if rank > RANK_1 {
+			sb.WriteByte('/')
 		}
 	}
 
-	if fen[fenPos] == 'w' {
-		b.Side = WHITE
+	sb.WriteByte(' ')
+	sb.WriteByte(SideChar[b.Side])
+	sb.WriteByte(' ')
+	sb.WriteString(b.castleFen())
+	sb.WriteByte(' ')
+
+	if b.EnPassant != NO_SQ {
+		sb.WriteString(PrintSq(b.EnPassant))
 	} else {
-		b.Side = BLACK
+		sb.WriteByte('-')
 	}
 
-	fenPos += 2
+	fmt.Fprintf(&sb, " %d %d", b.FiftyMove, b.FullMove)
 
-	for i := 0; i < 4; i++ {
-		if fen[fenPos] == ' ' {
-			break
+	return sb.String()
+}
+
+// castleFen returns the castling rights field of the FEN string.
+func (b *Board) castleFen() string {
+	castle := ""
+
+	for index, c := range "KQkq" {
+		if b.CastlePerm&(1<<index) == 0 {
+			continue
 		}
-		switch fen[fenPos] {
-		case 'K':
-			b.CastlePerm |= WKCA
-		case 'Q':
-			b.CastlePerm |= WQCA
-		case 'k':
-			b.CastlePerm |= BKCA
-		case 'q':
-			b.CastlePerm |= BQCA
+
+		side := index / 2
+		rook := b.CastleRooks[index]
+		if b.Chess960 || b.outerCastleRook(side, index%2 == 0) != rook {
+			c = rune('A' + FilesBrd[rook])
+			if side == BLACK {
+				c = rune('a' + FilesBrd[rook])
+			}
 		}
 
-		fenPos++
+		castle += string(c)
 	}
-	fenPos++
 
-	if fen[fenPos] != '-' {
-		file = int(fen[fenPos] - 'a')
-		fenPos++
-		rank = int(fen[fenPos] - '1')
+	if castle == "" {
+		return "-"
+	}
+
+	return castle
+}
+
+// outerCastleRook returns the square of the outermost rook of the given side on its back rank,
+// on the king side or on the queen side of the king, or NO_SQ if there is none.
+func (b *Board) outerCastleRook(side int, kingSide bool) int {
+	king := b.KingSq[side]
+
+	for i := FILE_A; i < FILE_NONE; i++ {
+		file := i
+		if kingSide {
+			file = FILE_H - i
+		}
 
-		b.EnPassant = FR2SQ(file, rank)
+		sq := FR2SQ(file, RanksBrd[king])
+		if sq == king {
+			break
+		}
+		if b.Pieces[sq] == SideRook[side] {
+			return sq
+		}
 	}
 
-	b.PosKey = GeneratePosKey(b)
-	UpdateListsMaterial(b)
+	return NO_SQ
 }
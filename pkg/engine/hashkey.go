@@ -35,18 +35,23 @@ var CastleKeys [16]uint64
 // Note: It is essential to call this function at the start of the chess engine to initialize the Zobrist
 // keys for later use.
 //
+// The keys are drawn from a fixed seed, so that they are the same from one run to the next and so are the searches
+// (the positions colliding in the hash table depend on the keys), which makes the node counts of cmd/bench comparable.
+//
 // Example usage:
 //   InitHashKeys() // Initializes Zobrist keys for position hashing.
 func InitHashKeys() {
+	r := rand.New(rand.NewSource(1))
+
 	for i := 0; i < 13; i++ {
 		for j := 0; j < 120; j++ {
-			PieceKeys[i][j] = rand.Uint64()
+			PieceKeys[i][j] = r.Uint64()
 		}
 	}
 
-	SideKey = rand.Uint64()
+	SideKey = r.Uint64()
 	for i := 0; i < 16; i++ {
-		CastleKeys[i] = rand.Uint64()
+		CastleKeys[i] = r.Uint64()
 	}
 }
 
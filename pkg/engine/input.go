@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+The commands of the GUI are read by a goroutine and passed through a channel, so that a search can look for new
+commands without blocking (see ReadInput, called from CheckUp). Polling the size of stdin does not work with pipes,
+which is how GUIs talk to engines.
+
+Commands received during a search that do not concern it are kept, and returned by ReadLine once the search is over.
+*/
+
+// Input reads the commands sent to the engine in the background.
+type Input struct {
+	lines   chan string
+	err     error // Error that ended the input (io.EOF at the end), set before lines is closed.
+	pending []string
+}
+
+// NewInput starts reading lines from r in the background.
+func NewInput(r io.Reader) *Input {
+	in := &Input{lines: make(chan string, 64)}
+
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			in.lines <- scanner.Text()
+		}
+
+		in.err = scanner.Err()
+		if in.err == nil {
+			in.err = io.EOF
+		}
+		close(in.lines)
+	}()
+
+	return in
+}
+
+// input returns the input of the protocol loops, reading stdin if none was set.
+func (s *SearchInfo) input() *Input {
+	if s.Input == nil {
+		s.Input = NewInput(os.Stdin)
+	}
+
+	return s.Input
+}
+
+// ReadLine returns the next command, waiting for it if needed. The error is io.EOF once the input is exhausted.
+func (in *Input) ReadLine() (string, error) {
+	if len(in.pending) > 0 {
+		line := in.pending[0]
+		in.pending = in.pending[1:]
+		return line, nil
+	}
+
+	line, ok := <-in.lines
+	if !ok {
+		return "", in.err
+	}
+
+	return line, nil
+}
+
+// poll returns the next command if one was received, without waiting. ok is false if there is none.
+// closed is true once the input is exhausted.
+func (in *Input) poll() (line string, ok bool, closed bool) {
+	select {
+	case line, ok := <-in.lines:
+		return line, ok, !ok
+	default:
+		return "", false, false
+	}
+}
+
+// keep saves a command received during a search, to be returned by ReadLine after it.
+func (in *Input) keep(line string) {
+	in.pending = append(in.pending, line)
+}
+
+// ReadInput handles the commands received while searching. The search is stopped by "stop" (UCI), "?" (xboard)
+// and by "quit" or the end of the input, which also make the protocol loop exit. "isready" is answered right away
+// as required by UCI and "ponderhit" starts the clock of a ponder search, everything else is kept for after the search.
+func ReadInput(s *SearchInfo) {
+	if s.Input == nil {
+		return
+	}
+
+	for {
+		line, ok, closed := s.Input.poll()
+		if closed {
+			s.Stopped = TRUE
+			s.Quit = TRUE
+			return
+		}
+		if !ok {
+			return
+		}
+
+		searchCommand(s, line)
+	}
+}
+
+// waitForStop waits for the GUI or the context of the search to stop an infinite search, or to stop a ponder search
+// or send ponderhit.
+func waitForStop(s *SearchInfo) {
+	var lines chan string
+	if s.Input != nil {
+		lines = s.Input.lines
+	}
+	var done <-chan struct{}
+	if s.ctx != nil {
+		done = s.ctx.Done()
+	}
+
+	// nothing could stop the search
+	if lines == nil && done == nil && s.ponderhit == nil {
+		return
+	}
+
+	for s.Stopped == FALSE && (s.Infinite == TRUE || s.Ponder == TRUE) {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				s.Stopped = TRUE
+				s.Quit = TRUE
+				return
+			}
+			searchCommand(s, line)
+		case <-done:
+			s.Stopped = TRUE
+		case <-s.ponderhit:
+			ponderHit(s)
+		}
+	}
+}
+
+// searchCommand handles a command received while searching.
+func searchCommand(s *SearchInfo, line string) {
+	command := ""
+	if fields := strings.Fields(line); len(fields) > 0 {
+		command = fields[0]
+	}
+
+	switch {
+	case command == "quit":
+		s.Stopped = TRUE
+		s.Quit = TRUE
+	case s.GameMode == UCIMODE && command == "stop":
+		s.Stopped = TRUE
+	case s.GameMode == UCIMODE && command == "isready":
+		fmt.Fprintf(s.out(), "readyok\n")
+	case s.GameMode == UCIMODE && command == "ponderhit":
+		ponderHit(s)
+	case s.GameMode == XBOARDMODE && command == "?":
+		s.Stopped = TRUE
+	default:
+		s.Input.keep(line)
+	}
+}
+
+// ponderHit turns a ponder search into a normal one, the opponent having played the expected move.
+// The time of the move starts now.
+func ponderHit(s *SearchInfo) {
+	if s.Ponder == FALSE {
+		return
+	}
+
+	s.Ponder = FALSE
+	if s.ponderTimeset == TRUE {
+		s.Timeset = TRUE
+		s.StopTime = time.Now().Add(s.ponderTime)
+	}
+}
@@ -0,0 +1,203 @@
+package engine
+
+/*
+Magic bitboards are a perfect hashing technique used to look up the attack sets of sliding pieces
+(rooks, bishops and queens) in a single table access.
+
+For every square we keep a mask of the squares whose occupancy can block the slider (the edges of the
+board are excluded since a piece standing there can never block anything behind it). The occupancy of
+the board ANDed with that mask is multiplied by a "magic" 64-bit constant and shifted right, which gives
+a dense index into a per-square table of precomputed attack sets:
+
+	index := ((occupancy & mask) * magic) >> shift
+	attacks := table[index]
+
+The magic constants are found at startup by trial and error with a deterministic random number generator,
+so the tables are identical on every run.
+*/
+
+// Magic holds everything needed to look up the attacks of a sliding piece from one square.
+type Magic struct {
+	Mask    uint64   // Relevant occupancy squares for the slider on this square.
+	Magic   uint64   // Magic multiplier mapping every relevant occupancy to a unique index.
+	Shift   uint     // 64 minus the number of relevant occupancy bits.
+	Attacks []uint64 // Attack sets indexed by the magic index.
+}
+
+// Index returns the attack table index for the given board occupancy.
+func (m *Magic) Index(occ uint64) uint64 {
+	return ((occ & m.Mask) * m.Magic) >> m.Shift
+}
+
+// RookMagics and BishopMagics hold the magic lookup data for every square (64-square indexing).
+var RookMagics [64]Magic
+var BishopMagics [64]Magic
+
+// KnightAttacks, KingAttacks and PawnAttacks hold the attack sets of the non sliding pieces for every
+// square (64-square indexing). PawnAttacks is indexed by the color of the attacking pawn.
+var KnightAttacks [64]uint64
+var KingAttacks [64]uint64
+var PawnAttacks [2][64]uint64
+
+var rookDeltas = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+var bishopDeltas = [4][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+var knightDeltas = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+var kingDeltas = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+
+// RookAttacks returns the squares attacked by a rook on sq (64-square indexing) given the board occupancy.
+func RookAttacks(sq int, occ uint64) uint64 {
+	m := &RookMagics[sq]
+	return m.Attacks[m.Index(occ)]
+}
+
+// BishopAttacks returns the squares attacked by a bishop on sq (64-square indexing) given the board occupancy.
+func BishopAttacks(sq int, occ uint64) uint64 {
+	m := &BishopMagics[sq]
+	return m.Attacks[m.Index(occ)]
+}
+
+// QueenAttacks returns the squares attacked by a queen on sq (64-square indexing) given the board occupancy.
+func QueenAttacks(sq int, occ uint64) uint64 {
+	return RookAttacks(sq, occ) | BishopAttacks(sq, occ)
+}
+
+// InitAttacks builds the attack tables of every piece type, including the magic tables of the sliders.
+func InitAttacks() {
+	for sq := 0; sq < 64; sq++ {
+		KnightAttacks[sq] = stepAttacks(sq, knightDeltas[:])
+		KingAttacks[sq] = stepAttacks(sq, kingDeltas[:])
+		PawnAttacks[WHITE][sq] = stepAttacks(sq, [][2]int{{-1, 1}, {1, 1}})
+		PawnAttacks[BLACK][sq] = stepAttacks(sq, [][2]int{{-1, -1}, {1, -1}})
+	}
+
+	for sq := 0; sq < 64; sq++ {
+		rng := magicRand{state: magicSeeds[sq/8]}
+		initMagic(&RookMagics[sq], sq, rookDeltas, &rng)
+		initMagic(&BishopMagics[sq], sq, bishopDeltas, &rng)
+	}
+}
+
+// magicSeeds are the generator seeds used for the squares of each rank. They are known to
+// produce valid magics after few attempts, which keeps the engine startup fast.
+var magicSeeds = [8]uint64{728, 10316, 55013, 32803, 12281, 15100, 16645, 255}
+
+// stepAttacks returns the squares reached from sq by a single step along each of the given deltas.
+func stepAttacks(sq int, deltas [][2]int) uint64 {
+	var attacks uint64
+	file, rank := sq%8, sq/8
+
+	for _, d := range deltas {
+		f, r := file+d[0], rank+d[1]
+		if FileRankValid(f) && FileRankValid(r) {
+			attacks |= 1 << (r*8 + f)
+		}
+	}
+
+	return attacks
+}
+
+// slidingAttacks computes the attacks of a slider on sq the slow way, by walking every direction
+// until the edge of the board or the first blocker (which is included in the attack set).
+func slidingAttacks(sq int, occ uint64, deltas [4][2]int) uint64 {
+	var attacks uint64
+	file, rank := sq%8, sq/8
+
+	for _, d := range deltas {
+		f, r := file+d[0], rank+d[1]
+		for FileRankValid(f) && FileRankValid(r) {
+			bit := uint64(1) << (r*8 + f)
+			attacks |= bit
+			if occ&bit != 0 {
+				break
+			}
+			f += d[0]
+			r += d[1]
+		}
+	}
+
+	return attacks
+}
+
+// slidingMask returns the relevant occupancy mask of a slider on sq: every square it can reach on an
+// empty board, except the last square in each direction.
+func slidingMask(sq int, deltas [4][2]int) uint64 {
+	var mask uint64
+	file, rank := sq%8, sq/8
+
+	for _, d := range deltas {
+		f, r := file+d[0], rank+d[1]
+		for FileRankValid(f+d[0]) && FileRankValid(r+d[1]) {
+			mask |= 1 << (r*8 + f)
+			f += d[0]
+			r += d[1]
+		}
+	}
+
+	return mask
+}
+
+// initMagic finds a magic number for the slider on sq and fills its attack table.
+// Every subset of the relevant mask is enumerated with the Carry-Rippler trick, and candidate magics
+// are tried until all subsets map to indexes that do not collide (or collide with identical attacks).
+func initMagic(m *Magic, sq int, deltas [4][2]int, rng *magicRand) {
+	m.Mask = slidingMask(sq, deltas)
+	bits := CountBits(m.Mask)
+	m.Shift = uint(64 - bits)
+	size := 1 << bits
+
+	occupancies := make([]uint64, 0, size)
+	references := make([]uint64, 0, size)
+
+	var occ uint64
+	for {
+		occupancies = append(occupancies, occ)
+		references = append(references, slidingAttacks(sq, occ, deltas))
+		occ = (occ - m.Mask) & m.Mask
+		if occ == 0 {
+			break
+		}
+	}
+
+	m.Attacks = make([]uint64, size)
+	epoch := make([]int, size)
+
+	for attempt := 1; ; attempt++ {
+		m.Magic = rng.sparse()
+		if CountBits((m.Mask*m.Magic)>>56) < 6 {
+			continue
+		}
+
+		ok := true
+		for i, o := range occupancies {
+			index := m.Index(o)
+			if epoch[index] < attempt {
+				epoch[index] = attempt
+				m.Attacks[index] = references[i]
+			} else if m.Attacks[index] != references[i] {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			return
+		}
+	}
+}
+
+// magicRand is a small xorshift64* generator, used so that the magic search is deterministic.
+type magicRand struct {
+	state uint64
+}
+
+func (r *magicRand) next() uint64 {
+	r.state ^= r.state >> 12
+	r.state ^= r.state << 25
+	r.state ^= r.state >> 27
+	return r.state * 2685821657736338717
+}
+
+// sparse returns a random number with few bits set, which makes good magic candidates.
+func (r *magicRand) sparse() uint64 {
+	return r.next() & r.next() & r.next()
+}
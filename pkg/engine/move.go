@@ -73,11 +73,24 @@ func GetPromoted(move int) int {
 // Note: This function handles both standard moves and moves with piece promotions, such as
 // pawn promotions to queen, rook, bishop, or knight.
 func PrintMove(move int) string {
-	fileFrom := FilesBrd[GetFrom(move)]
-	rankFrom := RanksBrd[GetFrom(move)]
+	return FormatMove(move, false)
+}
+
+// FormatMove converts an encoded chess move to coordinate notation, like PrintMove.
+// Castling moves are printed as the king moving to its destination square (e1g1) in standard chess,
+// and as the king taking its own rook (e1h1) when chess960 is true, as required by the UCI protocol.
+func FormatMove(move int, chess960 bool) string {
+	from := GetFrom(move)
+	to := GetToSq(move)
+	if move&MoveFlagCastle != 0 && !chess960 {
+		to, _ = CastleSquares(from, to)
+	}
 
-	fileTo := FilesBrd[GetToSq(move)]
-	rankTo := RanksBrd[GetToSq(move)]
+	fileFrom := FilesBrd[from]
+	rankFrom := RanksBrd[from]
+
+	fileTo := FilesBrd[to]
+	rankTo := RanksBrd[to]
 
 	promoted := GetPromoted(move)
 
@@ -97,7 +110,9 @@ func PrintMove(move int) string {
 	}
 }
 
-// ParseMove parses a chess move from the given algebraic notation string
+// ParseMove parses a chess move from the given algebraic notation string.
+// Castling is accepted as the king moving to its destination square (e1g1) or taking its own rook (e1h1),
+// except in Chess960 mode where only the latter is unambiguous.
 func ParseMove(move string, b *Board) (int, error) {
 	if len(move) < 4 {
 		return NOMOVE, nil
@@ -129,9 +144,19 @@ func ParseMove(move string, b *Board) (int, error) {
 
 	for i := 0; i < ml.Count; i++ {
 		m := ml.Moves[i].Move
+		if m&MoveFlagCastle != 0 && !b.Chess960 && GetFrom(m) == from {
+			if kingTo, _ := CastleSquares(from, GetToSq(m)); kingTo == to {
+				return m, nil
+			}
+		}
+
 		if GetFrom(m) == from && GetToSq(m) == to {
 			promotedPiece = GetPromoted(m)
 			if promotedPiece != EMPTY {
+				if len(move) < 5 {
+					continue
+				}
+
 				if PieceRookQueen[promotedPiece] != 0 && PieceBishopQueen[promotedPiece] == 0 && move[4] == 'r' {
 					return m, nil
 				} else if PieceRookQueen[promotedPiece] == 0 && PieceBishopQueen[promotedPiece] != 0 && move[4] == 'b' {
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"io"
 )
 
 type Move struct {
@@ -70,20 +71,21 @@ func NewMove(from, to, captured, promoted, fileRank int) int {
 //
 // Parameters:
 //   - ml: A pointer to the MoveList to be printed.
+//   - out: Where the MoveList is printed to.
 //
 // The printed output includes the move index (1-based), the move in standard
 // algebraic notation (e.g., "e2e4" for a pawn move from e2 to e4), and an
 // optional score associated with the move. This function is useful for examining
 // the list of generated moves during debugging or for displaying move options
 // to the user.
-func (ml *MoveList) PrintMoveList() {
-	fmt.Printf("MoveList: %d\n", ml.Count)
+func (ml *MoveList) PrintMoveList(out io.Writer) {
+	fmt.Fprintf(out, "MoveList: %d\n", ml.Count)
 
 	for i := 0; i < ml.Count; i++ {
-		fmt.Printf("Move: %d > %s (score: %d)\n", i+1, PrintMove(ml.Moves[i].Move), ml.Moves[i].Score)
+		fmt.Fprintf(out, "Move: %d > %s (score: %d)\n", i+1, PrintMove(ml.Moves[i].Move), ml.Moves[i].Score)
 	}
 
-	fmt.Printf("\n\n")
+	fmt.Fprintf(out, "\n\n")
 }
 
 // AddQuietMove adds a quiet (non-capturing) chess move to the MoveList.
@@ -250,304 +252,94 @@ func GenerateAllMoves(b *Board, ml *MoveList) {
 
 	side := b.Side
 
-	// panws
-	if side == WHITE {
-		for pieceNum := 0; pieceNum < b.PCENum[WP]; pieceNum++ {
-			sq := b.PList[WP][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
-
-			if b.Pieces[sq+10] == EMPTY {
-				ml.AddWhitePawnMove(b, sq, sq+10)
-				if RanksBrd[sq] == RANK_2 && b.Pieces[sq+20] == EMPTY {
-					ml.AddQuietMove(b, NewMove(sq, sq+20, EMPTY, EMPTY, MoveFlagPawnStart))
-				}
-			}
-
-			if SqOnBoard(sq+9) && PieceCol[b.Pieces[sq+9]] == BLACK {
-				ml.AddWhitePawnCaptureMove(b, sq, sq+9, b.Pieces[sq+9])
-			}
-			if SqOnBoard(sq+11) && PieceCol[b.Pieces[sq+11]] == BLACK {
-				ml.AddWhitePawnCaptureMove(b, sq, sq+11, b.Pieces[sq+11])
-			}
-
-			if b.EnPassant != NO_SQ {
-				if sq+9 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq+9, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
-				if sq+11 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq+11, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
-			}
-		}
-
-		if b.CastlePerm&WKCA != 0 {
-			if b.Pieces[F1] == EMPTY && b.Pieces[G1] == EMPTY {
-				if SqAttacked(E1, BLACK, b) == 0 && SqAttacked(F1, BLACK, b) == 0 {
-					ml.AddQuietMove(b, NewMove(E1, G1, EMPTY, EMPTY, MoveFlagCastle))
-				}
-			}
-		}
-
-		if b.CastlePerm&WQCA != 0 {
-			if b.Pieces[D1] == EMPTY && b.Pieces[C1] == EMPTY && b.Pieces[B1] == EMPTY {
-				if SqAttacked(E1, BLACK, b) == 0 && SqAttacked(D1, BLACK, b) == 0 {
-					ml.AddQuietMove(b, NewMove(E1, C1, EMPTY, EMPTY, MoveFlagCastle))
-				}
-			}
-		}
-	} else {
-		for pieceNum := 0; pieceNum < b.PCENum[BP]; pieceNum++ {
-			sq := b.PList[BP][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
-
-			if b.Pieces[sq-10] == EMPTY {
-				ml.AddBlackPawnMove(b, sq, sq-10)
-				if RanksBrd[sq] == RANK_7 && b.Pieces[sq-20] == EMPTY {
-					ml.AddQuietMove(b, NewMove(sq, sq-20, EMPTY, EMPTY, MoveFlagPawnStart))
-				}
-			}
-
-			if SqOnBoard(sq-9) && PieceCol[b.Pieces[sq-9]] == WHITE {
-				ml.AddBlackPawnCaptureMove(b, sq, sq-9, b.Pieces[sq-9])
-			}
-			if SqOnBoard(sq-11) && PieceCol[b.Pieces[sq-11]] == WHITE {
-				ml.AddBlackPawnCaptureMove(b, sq, sq-11, b.Pieces[sq-11])
-			}
-
-			if b.EnPassant != NO_SQ {
-				if sq-9 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq-9, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
-				if sq-11 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq-11, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
-			}
-		}
-
-		if b.CastlePerm&BKCA != 0 {
-			if b.Pieces[F8] == EMPTY && b.Pieces[G8] == EMPTY {
-				if SqAttacked(E8, WHITE, b) == 0 && SqAttacked(F8, WHITE, b) == 0 {
-					ml.AddQuietMove(b, NewMove(E8, G8, EMPTY, EMPTY, MoveFlagCastle))
-				}
-			}
-		}
-
-		if b.CastlePerm&BQCA != 0 {
-			if b.Pieces[D8] == EMPTY && b.Pieces[C8] == EMPTY && b.Pieces[B8] == EMPTY {
-				if SqAttacked(E8, WHITE, b) == 0 && SqAttacked(D8, WHITE, b) == 0 {
-					ml.AddQuietMove(b, NewMove(E8, C8, EMPTY, EMPTY, MoveFlagCastle))
-				}
-			}
-		}
-	}
-
-	// slide pieces
-	pieceIndex := LoopSlideIndex[side]
-	piece := LoopSlidePieces[pieceIndex]
-	pieceIndex++
-	for piece != 0 {
-		if !PieceValid(piece) {
-			panic("piece not valid")
-		}
-
-		for pieceNum := 0; pieceNum < b.PCENum[piece]; pieceNum++ {
-			sq := b.PList[piece][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
-
-			for i := 0; i < NumDir[piece]; i++ {
-				dir := PieceDir[piece][i]
-				tSq := sq + dir
-
-				for SqOnBoard(tSq) {
-					if b.Pieces[tSq] != EMPTY {
-						if PieceCol[b.Pieces[tSq]] == side^1 {
-							ml.AddCaptureMove(b, NewMove(sq, tSq, b.Pieces[tSq], EMPTY, 0))
-						}
-						break
-					}
-
-					ml.AddQuietMove(b, NewMove(sq, tSq, EMPTY, EMPTY, 0))
-					tSq += dir
-				}
+	ml.generatePawnMoves(b, TRUE)
 
-			}
-		}
-
-		piece = LoopSlidePieces[pieceIndex]
-		pieceIndex++
-	}
-
-	// non slide pieces
-	pieceIndex = LoopNonSlideIndex[side]
-	piece = LoopNonSlidePieces[pieceIndex]
-	pieceIndex++
-	for piece != 0 {
-		if !PieceValid(piece) {
-			panic("piece not valid")
-		}
-
-		for pieceNum := 0; pieceNum < b.PCENum[piece]; pieceNum++ {
-			sq := b.PList[piece][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
-
-			for i := 0; i < NumDir[piece]; i++ {
-				dir := PieceDir[piece][i]
-				tSq := sq + dir
-
-				if SqOffBoard(tSq) {
-					continue
-				}
-
-				if b.Pieces[tSq] != EMPTY {
-					if PieceCol[b.Pieces[tSq]] == side^1 {
-						ml.AddCaptureMove(b, NewMove(sq, tSq, b.Pieces[tSq], EMPTY, 0))
-					}
-					continue
-				}
+	ml.generateCastleMoves(b)
 
-				ml.AddQuietMove(b, NewMove(sq, tSq, EMPTY, EMPTY, 0))
-			}
-		}
-
-		piece = LoopNonSlidePieces[pieceIndex]
-		pieceIndex++
-	}
+	ml.generatePieceMoves(b, ^b.Occupancy[side])
 }
 
+// GenerateAllCaptures generates the capturing moves (including en passant and capture promotions)
+// for the current board position. It is used by the quiescence search.
 func GenerateAllCaptures(b *Board, ml *MoveList) {
 	b.CheckBoard()
 
 	ml.Count = 0
 
+	ml.generatePawnMoves(b, FALSE)
+	ml.generatePieceMoves(b, b.Occupancy[b.Side^1])
+}
+
+// generatePawnMoves adds the pawn captures (including en passant) of the side to move,
+// and the pawn pushes as well if quiet is TRUE.
+func (ml *MoveList) generatePawnMoves(b *Board, quiet int) {
 	side := b.Side
+	pawns := b.Bitboards[SidePawn[side]]
+	enemies := b.Occupancy[side^1]
+	occ := b.Occupancy[BOTH]
+
+	forward := 10
+	startRank := RANK_2
+	if side == BLACK {
+		forward = -10
+		startRank = RANK_7
+	}
 
-	// panws
-	if side == WHITE {
-		for pieceNum := 0; pieceNum < b.PCENum[WP]; pieceNum++ {
-			sq := b.PList[WP][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
+	for pawns != 0 {
+		sq64 := PopBit(&pawns)
+		sq := SQ120[sq64]
 
-			if SqOnBoard(sq+9) && PieceCol[b.Pieces[sq+9]] == BLACK {
-				ml.AddWhitePawnCaptureMove(b, sq, sq+9, b.Pieces[sq+9])
-			}
-			if SqOnBoard(sq+11) && PieceCol[b.Pieces[sq+11]] == BLACK {
-				ml.AddWhitePawnCaptureMove(b, sq, sq+11, b.Pieces[sq+11])
+		if quiet == TRUE && occ&SetMask[SQ64[sq+forward]] == 0 {
+			if side == WHITE {
+				ml.AddWhitePawnMove(b, sq, sq+forward)
+			} else {
+				ml.AddBlackPawnMove(b, sq, sq+forward)
 			}
 
-			if b.EnPassant != NO_SQ {
-				if sq+9 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq+9, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
-				if sq+11 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq+11, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
+			if RanksBrd[sq] == startRank && occ&SetMask[SQ64[sq+2*forward]] == 0 {
+				ml.AddQuietMove(b, NewMove(sq, sq+2*forward, EMPTY, EMPTY, MoveFlagPawnStart))
 			}
 		}
-	} else {
-		for pieceNum := 0; pieceNum < b.PCENum[BP]; pieceNum++ {
-			sq := b.PList[BP][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
 
-			if SqOnBoard(sq-9) && PieceCol[b.Pieces[sq-9]] == WHITE {
-				ml.AddBlackPawnCaptureMove(b, sq, sq-9, b.Pieces[sq-9])
-			}
-			if SqOnBoard(sq-11) && PieceCol[b.Pieces[sq-11]] == WHITE {
-				ml.AddBlackPawnCaptureMove(b, sq, sq-11, b.Pieces[sq-11])
-			}
-
-			if b.EnPassant != NO_SQ {
-				if sq-9 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq-9, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
-				if sq-11 == b.EnPassant {
-					ml.AddEnPassantMove(NewMove(sq, sq-11, EMPTY, EMPTY, MoveFlagEnPassant))
-				}
+		attacks := PawnAttacks[side][sq64] & enemies
+		for attacks != 0 {
+			to := SQ120[PopBit(&attacks)]
+			if side == WHITE {
+				ml.AddWhitePawnCaptureMove(b, sq, to, b.Pieces[to])
+			} else {
+				ml.AddBlackPawnCaptureMove(b, sq, to, b.Pieces[to])
 			}
 		}
-	}
 
-	// slide pieces
-	pieceIndex := LoopSlideIndex[side]
-	piece := LoopSlidePieces[pieceIndex]
-	pieceIndex++
-	for piece != 0 {
-		if !PieceValid(piece) {
-			panic("piece not valid")
+		if b.EnPassant != NO_SQ && PawnAttacks[side][sq64]&SetMask[SQ64[b.EnPassant]] != 0 {
+			ml.AddEnPassantMove(NewMove(sq, b.EnPassant, EMPTY, EMPTY, MoveFlagEnPassant))
 		}
-
-		for pieceNum := 0; pieceNum < b.PCENum[piece]; pieceNum++ {
-			sq := b.PList[piece][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
-
-			for i := 0; i < NumDir[piece]; i++ {
-				dir := PieceDir[piece][i]
-				tSq := sq + dir
-
-				for SqOnBoard(tSq) {
-					if b.Pieces[tSq] != EMPTY {
-						if PieceCol[b.Pieces[tSq]] == side^1 {
-							ml.AddCaptureMove(b, NewMove(sq, tSq, b.Pieces[tSq], EMPTY, 0))
-						}
-						break
-					}
-					tSq += dir
-				}
-
-			}
-		}
-
-		piece = LoopSlidePieces[pieceIndex]
-		pieceIndex++
 	}
+}
 
-	// non slide pieces
-	pieceIndex = LoopNonSlideIndex[side]
-	piece = LoopNonSlidePieces[pieceIndex]
-	pieceIndex++
-	for piece != 0 {
-		if !PieceValid(piece) {
-			panic("piece not valid")
-		}
-
-		for pieceNum := 0; pieceNum < b.PCENum[piece]; pieceNum++ {
-			sq := b.PList[piece][pieceNum]
-			if SqOffBoard(sq) {
-				panic("sq offboard")
-			}
-
-			for i := 0; i < NumDir[piece]; i++ {
-				dir := PieceDir[piece][i]
-				tSq := sq + dir
-
-				if SqOffBoard(tSq) {
-					continue
-				}
-
-				if b.Pieces[tSq] != EMPTY {
-					if PieceCol[b.Pieces[tSq]] == side^1 {
-						ml.AddCaptureMove(b, NewMove(sq, tSq, b.Pieces[tSq], EMPTY, 0))
-					}
-					continue
+// generatePieceMoves adds the knight, bishop, rook, queen and king moves of the side to move
+// whose destination is one of the squares in targets.
+func (ml *MoveList) generatePieceMoves(b *Board, targets uint64) {
+	side := b.Side
+	occ := b.Occupancy[BOTH]
+	pieces := [5]int{SideKnight[side], SideBishop[side], SideRook[side], SideQueen[side], SideKing[side]}
+
+	for _, piece := range pieces {
+		bb := b.Bitboards[piece]
+		for bb != 0 {
+			sq64 := PopBit(&bb)
+			from := SQ120[sq64]
+
+			attacks := PieceAttacks(piece, sq64, occ) & targets
+			for attacks != 0 {
+				to := SQ120[PopBit(&attacks)]
+				if b.Pieces[to] != EMPTY {
+					ml.AddCaptureMove(b, NewMove(from, to, b.Pieces[to], EMPTY, 0))
+				} else {
+					ml.AddQuietMove(b, NewMove(from, to, EMPTY, EMPTY, 0))
 				}
 			}
 		}
-
-		piece = LoopNonSlidePieces[pieceIndex]
-		pieceIndex++
 	}
 }
 
@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Types of the engine options, as named by the UCI protocol.
+const (
+	OptionSpin   = "spin"
+	OptionCheck  = "check"
+	OptionCombo  = "combo"
+	OptionString = "string"
+	OptionButton = "button"
+)
+
+// DefaultMoveOverhead is the default of the Move Overhead option, in milliseconds.
+const DefaultMoveOverhead = 50
+
+// Option is an engine option, advertised to the GUI and set with the UCI setoption or the xboard option command.
+type Option struct {
+	Name    string
+	Type    string
+	Default string
+	Min     int      // Spin options only.
+	Max     int      // Spin options only.
+	Vars    []string // Values of combo options.
+	UCIOnly bool     // Not advertised to xboard GUIs, which have their own commands for it.
+
+	// set applies a value that was checked against the type of the option ("" for buttons).
+	set func(b *Board, s *SearchInfo, value string) error
+}
+
+// Options is the registry of the engine options.
+var Options = []*Option{
+	{Name: "Hash", Type: OptionSpin, Default: "64", Min: 4, Max: 2048, set: func(b *Board, s *SearchInfo, value string) error {
+		MB, _ := strconv.Atoi(value)
+		InitHashTable(b, MB)
+		return nil
+	}},
+	{Name: "Clear Hash", Type: OptionButton, set: func(b *Board, s *SearchInfo, value string) error {
+		ClearHashTable(b)
+		return nil
+	}},
+	{Name: "Threads", Type: OptionSpin, Default: "1", Min: 1, Max: MAXTHREADS, set: func(b *Board, s *SearchInfo, value string) error {
+		s.Threads, _ = strconv.Atoi(value)
+		return nil
+	}},
+	{Name: "MultiPV", Type: OptionSpin, Default: "1", Min: 1, Max: MAXPOSITIONMOVES, set: func(b *Board, s *SearchInfo, value string) error {
+		s.MultiPV, _ = strconv.Atoi(value)
+		return nil
+	}},
+	{Name: "Ponder", Type: OptionCheck, Default: "false", UCIOnly: true, set: func(b *Board, s *SearchInfo, value string) error {
+		// the GUI decides when to ponder, the engine only needs to know that it may have to
+		return nil
+	}},
+	{Name: "Move Overhead", Type: OptionSpin, Default: strconv.Itoa(DefaultMoveOverhead), Min: 0, Max: 5000, set: func(b *Board, s *SearchInfo, value string) error {
+		s.MoveOverhead, _ = strconv.Atoi(value)
+		return nil
+	}},
+	{Name: "UCI_Chess960", Type: OptionCheck, Default: "false", UCIOnly: true, set: func(b *Board, s *SearchInfo, value string) error {
+		b.Chess960 = value == "true"
+		return nil
+	}},
+	{Name: "OwnBook", Type: OptionCheck, Default: "false", set: func(b *Board, s *SearchInfo, value string) error {
+		s.OwnBook = FALSE
+		if value == "true" {
+			s.OwnBook = TRUE
+		}
+		return nil
+	}},
+	{Name: "BookFile", Type: OptionString, Default: "<empty>", set: func(b *Board, s *SearchInfo, value string) error {
+		return s.SetBookFile(value)
+	}},
+	{Name: "SyzygyPath", Type: OptionString, Default: "<empty>", set: func(b *Board, s *SearchInfo, value string) error {
+		// accepted so that GUIs can set it, the tablebases are not probed yet
+		s.SyzygyPath = ""
+		if value != "<empty>" {
+			s.SyzygyPath = value
+		}
+		return nil
+	}},
+	toggleOption("LateMoveReductions", func(t *SearchToggles) *bool { return &t.NoLateMoveReductions }),
+	toggleOption("LateMovePruning", func(t *SearchToggles) *bool { return &t.NoLateMovePruning }),
+	toggleOption("ReverseFutilityPruning", func(t *SearchToggles) *bool { return &t.NoReverseFutilityPruning }),
+	toggleOption("FutilityPruning", func(t *SearchToggles) *bool { return &t.NoFutilityPruning }),
+	toggleOption("Razoring", func(t *SearchToggles) *bool { return &t.NoRazoring }),
+	toggleOption("DeltaPruning", func(t *SearchToggles) *bool { return &t.NoDeltaPruning }),
+	toggleOption("NullMovePruning", func(t *SearchToggles) *bool { return &t.NoNullMovePruning }),
+	evalOption("PawnIsolated", func(p *EvalParams) *int { return &p.PawnIsolated }, -100, 0),
+	evalOption("RookOpenFile", func(p *EvalParams) *int { return &p.RookOpenFile }, 0, 100),
+	evalOption("RookSemiOpenFile", func(p *EvalParams) *int { return &p.RookSemiOpenFile }, 0, 100),
+	evalOption("QueenOpenFile", func(p *EvalParams) *int { return &p.QueenOpenFile }, 0, 100),
+	evalOption("QueenSemiOpenFile", func(p *EvalParams) *int { return &p.QueenSemiOpenFile }, 0, 100),
+	evalOption("BishopPair", func(p *EvalParams) *int { return &p.BishopPair }, 0, 200),
+}
+
+// toggleOption returns a check option turning a part of the search on or off, on by default.
+func toggleOption(name string, off func(t *SearchToggles) *bool) *Option {
+	return &Option{Name: name, Type: OptionCheck, Default: "true", set: func(b *Board, s *SearchInfo, value string) error {
+		*off(&s.Toggles) = value == "false"
+		return nil
+	}}
+}
+
+// evalOption returns a spin option setting an evaluation term of the board, in centipawns. The board gets its own
+// copy of the defaults the first time one of them is set.
+func evalOption(name string, param func(p *EvalParams) *int, min, max int) *Option {
+	return &Option{Name: name, Type: OptionSpin, Default: strconv.Itoa(*param(&DefaultEvalParams)), Min: min, Max: max, set: func(b *Board, s *SearchInfo, value string) error {
+		if b.Eval == nil {
+			params := DefaultEvalParams
+			b.Eval = &params
+		}
+		*param(b.Eval), _ = strconv.Atoi(value)
+		return nil
+	}}
+}
+
+// FindOption returns the option with the given name, ignoring case as allowed by UCI, or nil.
+func FindOption(name string) *Option {
+	for _, o := range Options {
+		if strings.EqualFold(o.Name, name) {
+			return o
+		}
+	}
+
+	return nil
+}
+
+// SetOption checks a value against the type of the option with the given name and applies it.
+func SetOption(b *Board, s *SearchInfo, name, value string) error {
+	o := FindOption(name)
+	if o == nil {
+		return fmt.Errorf("unknown option %q", name)
+	}
+
+	switch o.Type {
+	case OptionSpin:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for option %s: not a number", value, o.Name)
+		}
+		if n < o.Min || n > o.Max {
+			return fmt.Errorf("invalid value %d for option %s: not between %d and %d", n, o.Name, o.Min, o.Max)
+		}
+		value = strconv.Itoa(n)
+	case OptionCheck:
+		value = strings.ToLower(value)
+		if value != "true" && value != "false" {
+			return fmt.Errorf("invalid value %q for option %s: not true or false", value, o.Name)
+		}
+	case OptionCombo:
+		found := false
+		for _, v := range o.Vars {
+			if strings.EqualFold(v, value) {
+				value, found = v, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid value %q for option %s: not one of %s", value, o.Name, strings.Join(o.Vars, ", "))
+		}
+	case OptionButton:
+		value = ""
+	}
+
+	return o.set(b, s, value)
+}
+
+// ParseSetOption parses a UCI setoption command: setoption name <id> [value <x>]. The name and the value may contain spaces.
+func ParseSetOption(line string) (name, value string, err error) {
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "setoption"))
+	if !strings.HasPrefix(line, "name ") {
+		return "", "", fmt.Errorf("invalid setoption command: no name")
+	}
+	line = strings.TrimPrefix(line, "name ")
+
+	name = line
+	if i := strings.Index(line, " value"); i >= 0 && (len(line) == i+len(" value") || line[i+len(" value")] == ' ') {
+		name, value = line[:i], strings.TrimSpace(line[i+len(" value"):])
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", fmt.Errorf("invalid setoption command: no name")
+	}
+
+	return name, value, nil
+}
+
+// UCI returns the line advertising the option to a UCI GUI.
+func (o *Option) UCI() string {
+	line := fmt.Sprintf("option name %s type %s", o.Name, o.Type)
+
+	switch o.Type {
+	case OptionSpin:
+		line += fmt.Sprintf(" default %s min %d max %d", o.Default, o.Min, o.Max)
+	case OptionCheck, OptionString:
+		line += " default " + o.Default
+	case OptionCombo:
+		line += " default " + o.Default
+		for _, v := range o.Vars {
+			line += " var " + v
+		}
+	}
+
+	return line
+}
+
+// XBoard returns the feature advertising the option to an xboard GUI.
+func (o *Option) XBoard() string {
+	switch o.Type {
+	case OptionSpin:
+		return fmt.Sprintf("feature option=\"%s -spin %s %d %d\"", o.Name, o.Default, o.Min, o.Max)
+	case OptionCheck:
+		value := 0
+		if o.Default == "true" {
+			value = 1
+		}
+		return fmt.Sprintf("feature option=\"%s -check %d\"", o.Name, value)
+	case OptionCombo:
+		vars := make([]string, len(o.Vars))
+		for i, v := range o.Vars {
+			if v == o.Default {
+				v = "*" + v
+			}
+			vars[i] = v
+		}
+		return fmt.Sprintf("feature option=\"%s -combo %s\"", o.Name, strings.Join(vars, " /// "))
+	case OptionButton:
+		return fmt.Sprintf("feature option=\"%s -button\"", o.Name)
+	default:
+		return fmt.Sprintf("feature option=\"%s -string %s\"", o.Name, o.Default)
+	}
+}
+
+// ParseXBoardOption parses the xboard option command: option NAME=VALUE, or option NAME for buttons.
+// The 0/1 values of check options are converted to false/true.
+func ParseXBoardOption(line string) (name, value string, err error) {
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "option"))
+
+	name, value, _ = strings.Cut(line, "=")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", fmt.Errorf("invalid option command: no name")
+	}
+
+	if o := FindOption(name); o != nil && o.Type == OptionCheck {
+		switch value {
+		case "0":
+			value = "false"
+		case "1":
+			value = "true"
+		}
+	}
+
+	return name, value, nil
+}
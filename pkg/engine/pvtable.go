@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"math/bits"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -11,106 +13,246 @@ const (
 	HFEXACT
 )
 
+// HashEntry is the decoded content of a slot of the hash table.
 type HashEntry struct {
-	PosKey uint64
-	Move   int
-	Score  int
-	Depth  int
-	Flags  int
+	PosKey     uint64
+	Move       int
+	Score      int
+	Depth      int
+	Flags      int
+	Generation int
 }
 
+/*
+The hash table is shared by all the threads of a search without any lock. Every entry is packed in a single 64 bits
+word (data) next to the position key, and the slot stores key^data instead of the key itself:
+
+	data bits  0-24 -> move
+	data bits 25-40 -> score + 32768
+	data bits 41-48 -> depth
+	data bits 49-50 -> flags
+	data bits 51-56 -> generation of the search that stored the entry
+
+Both words are read and written atomically, but not together, so a slot may be read while another thread is halfway
+through writing it. In that case the key recomputed as key^data does not match the position anymore and the entry
+is simply ignored, so a torn entry can never be used for the wrong position.
+
+The slots are grouped in buckets of hashBucketSlots slots (64 bytes, a cache line). A position can be stored in any
+slot of its bucket; when the bucket is full, the entry with the lowest depth is replaced, entries left by older
+searches counting as shallower the older they are.
+*/
+
+const (
+	hashBucketSlots = 4
+	hashGenerations = 64 // the generation is stored on 6 bits
+)
+
+type hashSlot struct {
+	key  uint64 // position key ^ data
+	data uint64
+}
+
+type hashBucket [hashBucketSlots]hashSlot
+
 type HashTable struct {
-	Table      []HashEntry
-	NumEntries int
-	NewWrite   int
-	OverWrite  int
-	Hit        int
-	Cut        int
-}
-
-// HashEntry has a size of 40 bytes on x64 systems.
-// In order to set a limit to our HashTable in terms of MB, the internal representation of the HashTable
-// is a []HashEntry with a preallocated capacity of 1024 * 1024 * MB (size in bytes). We calculate the maximum number of entries
-// in our HashTable by taking the desired size in bytes (1024 * 1024 * MB) and dividing it by the size of one HashEntry (40 bytes)
-// Our HashTable will never grow beyound the predefined limit (in terms of MB but) - but it will also never grow beyond b.HashTable.NumEntries
-// because when we store something into it, we use the zobrist key (uint64) % number of elements - the result of this operation is always
-// a number between 0 and b.HashTable.NumEntries.
+	Table      []hashBucket
+	NumBuckets int
+	Generation int // Bumped at the start of every search, to age the entries of the previous ones.
+}
+
+// packHashData packs an entry in the data word of a slot. The score must fit in 16 bits and the depth in 8 bits.
+func packHashData(move, score, depth, flags, generation int) uint64 {
+	if depth < 0 {
+		depth = 0
+	} else if depth > 255 {
+		depth = 255
+	}
+
+	return uint64(move)&0x1FFFFFF |
+		uint64(score+32768)&0xFFFF<<25 |
+		uint64(depth)<<41 |
+		uint64(flags)&3<<49 |
+		uint64(generation)&(hashGenerations-1)<<51
+}
+
+// unpackHashData decodes the data word of a slot.
+func unpackHashData(data uint64) HashEntry {
+	return HashEntry{
+		Move:       int(data & 0x1FFFFFF),
+		Score:      int(data>>25&0xFFFF) - 32768,
+		Depth:      int(data >> 41 & 0xFF),
+		Flags:      int(data >> 49 & 3),
+		Generation: int(data >> 51 & (hashGenerations - 1)),
+	}
+}
+
+// InitHashTable allocates a hash table of the given size in MB. Every bucket takes 64 bytes, so the table holds
+// 1024 * 1024 * MB / 64 buckets of hashBucketSlots entries.
 func InitHashTable(b *Board, MB int) {
-	entrySize := unsafe.Sizeof(HashEntry{}) // 40 bytes
+	bucketSize := int(unsafe.Sizeof(hashBucket{})) // 64 bytes
 
-	HashSize := 1024 * 1024 * MB
-	b.HashTable.NumEntries = HashSize / int(entrySize)
+	numBuckets := 1024 * 1024 * MB / bucketSize
+	if numBuckets < 1 {
+		numBuckets = 1
+	}
 
-	b.HashTable.Table = make([]HashEntry, b.HashTable.NumEntries)
+	b.HashTable = &HashTable{NumBuckets: numBuckets, Table: make([]hashBucket, numBuckets)}
 }
 
 func ClearHashTable(b *Board) {
-	b.HashTable.Table = make([]HashEntry, b.HashTable.NumEntries)
-	b.HashTable.NewWrite = 0
+	for i := range b.HashTable.Table {
+		b.HashTable.Table[i] = hashBucket{}
+	}
+	b.HashTable.Generation = 0
 }
 
-func StoreHashEntry(b *Board, move int, score int, flags int, depth int) {
-	// by doing this, we limit the number of entries to our defined size in MB
-	index := b.PosKey % uint64(b.HashTable.NumEntries)
-	if b.HashTable.Table[index].PosKey == 0 {
-		b.HashTable.NewWrite++
-	} else {
-		b.HashTable.OverWrite++
+// NewSearch ages the entries of the table, it is called once before every search.
+func (ht *HashTable) NewSearch() {
+	ht.Generation = (ht.Generation + 1) % hashGenerations
+}
+
+// bucket returns the bucket of a position key. The high 64 bits of key * NumBuckets map the key uniformly
+// to [0, NumBuckets) without a division.
+func (ht *HashTable) bucket(key uint64) *hashBucket {
+	index, _ := bits.Mul64(key, uint64(ht.NumBuckets))
+	return &ht.Table[index]
+}
+
+// load reads a slot, returning its data word if it holds an entry for the given key.
+func (slot *hashSlot) load(key uint64) (uint64, bool) {
+	data := atomic.LoadUint64(&slot.data)
+	if data == 0 || atomic.LoadUint64(&slot.key)^data != key {
+		return 0, false
+	}
+
+	return data, true
+}
+
+func (slot *hashSlot) store(key, data uint64) {
+	atomic.StoreUint64(&slot.key, key^data)
+	atomic.StoreUint64(&slot.data, data)
+}
+
+// probe returns the entry stored for the key, if any.
+func (ht *HashTable) probe(key uint64) (HashEntry, bool) {
+	bucket := ht.bucket(key)
+	for i := range bucket {
+		if data, ok := bucket[i].load(key); ok {
+			entry := unpackHashData(data)
+			entry.PosKey = key
+			return entry, true
+		}
 	}
 
+	return HashEntry{}, false
+}
+
+// age returns how many searches ago an entry was stored.
+func (ht *HashTable) age(generation int) int {
+	return (ht.Generation - generation + hashGenerations) % hashGenerations
+}
+
+func StoreHashEntry(b *Board, move int, score int, flags int, depth int) {
+	ht := b.HashTable
+	bucket := ht.bucket(b.PosKey)
+
+	// mate scores count the plies from the root, the table stores them as plies from the position itself,
+	// since the same position can be reached at another ply (ProbeHashEntry converts them back)
 	if score > ISMATE {
 		score += b.Ply
 	} else if score < -ISMATE {
 		score -= b.Ply
 	}
 
-	b.HashTable.Table[index] = HashEntry{
-		Move:   move,
-		PosKey: b.PosKey,
-		Flags:  flags,
-		Score:  score,
-		Depth:  depth,
+	// the slot of the same position if there is one, else an empty slot, else the least valuable entry
+	replace := &bucket[0]
+	replaceValue := INFINITE
+	for i := range bucket {
+		slot := &bucket[i]
+
+		data := atomic.LoadUint64(&slot.data)
+		if data == 0 {
+			if replaceValue > -INFINITE {
+				replace, replaceValue = slot, -INFINITE
+			}
+			continue
+		}
+
+		if atomic.LoadUint64(&slot.key)^data == b.PosKey {
+			// keep the best move found by a previous search of the position when this one has none
+			if move == NOMOVE {
+				move = unpackHashData(data).Move
+			}
+			replace = slot
+			break
+		}
+
+		entry := unpackHashData(data)
+		if value := entry.Depth - 4*ht.age(entry.Generation); value < replaceValue {
+			replace, replaceValue = slot, value
+		}
 	}
+
+	replace.store(b.PosKey, packHashData(move, score, depth, flags, ht.Generation))
 }
 
 func ProbeHashEntry(b *Board, move *int, score *int, alpha int, beta int, depth int) int {
-	index := b.PosKey % uint64(b.HashTable.NumEntries)
-	if b.HashTable.Table[index].PosKey == b.PosKey {
-		*move = b.HashTable.Table[index].Move
-		if b.HashTable.Table[index].Depth >= depth {
-			b.HashTable.Hit++
-
-			*score = b.HashTable.Table[index].Score
-			if *score > ISMATE {
-				*score -= b.Ply
-			} else {
-				if *score < -ISMATE {
-					*score += b.Ply
-				}
-			}
+	entry, ok := b.HashTable.probe(b.PosKey)
+	if !ok {
+		return FALSE
+	}
 
-			switch b.HashTable.Table[index].Flags {
-			case HFALPHA:
-				if *score <= alpha {
-					*score = alpha
-					return TRUE
-				}
-			case HFBETA:
-				if *score >= beta {
-					*score = beta
-					return TRUE
-				}
-			case HFEXACT:
-				return TRUE
-			default:
-				panic(false)
-			}
+	*move = entry.Move
+	if entry.Depth < depth {
+		return FALSE
+	}
+
+	*score = entry.Score
+	if *score > ISMATE {
+		*score -= b.Ply
+	} else if *score < -ISMATE {
+		*score += b.Ply
+	}
+
+	switch entry.Flags {
+	case HFALPHA:
+		if *score <= alpha {
+			*score = alpha
+			return TRUE
+		}
+	case HFBETA:
+		if *score >= beta {
+			*score = beta
+			return TRUE
 		}
+	case HFEXACT:
+		return TRUE
 	}
 
 	return FALSE
 }
 
+// Hashfull returns how full the table is in permille, estimated from the entries of the current search in the
+// first 1000 slots, as reported by the UCI "info hashfull" output.
+func (ht *HashTable) Hashfull() int {
+	used, slots := 0, 0
+	for i := 0; i < len(ht.Table) && slots < 1000; i++ {
+		for j := range ht.Table[i] {
+			data := atomic.LoadUint64(&ht.Table[i][j].data)
+			if data != 0 && unpackHashData(data).Generation == ht.Generation {
+				used++
+			}
+			slots++
+		}
+	}
+
+	if slots == 0 {
+		return 0
+	}
+
+	return used * 1000 / slots
+}
+
 func GetPvLine(depth int, b *Board) int {
 	move := ProbePvMove(b)
 	count := 0
@@ -144,9 +286,8 @@ func GetPvLine(depth int, b *Board) int {
 }
 
 func ProbePvMove(b *Board) int {
-	index := b.PosKey % uint64(b.HashTable.NumEntries)
-	if b.HashTable.Table[index].PosKey == b.PosKey {
-		return b.HashTable.Table[index].Move
+	if entry, ok := b.HashTable.probe(b.PosKey); ok {
+		return entry.Move
 	}
 
 	return NOMOVE
@@ -0,0 +1,263 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Standard Algebraic Notation (SAN) is the notation used by humans and by PGN files, e.g. Nf3, exd5, O-O, e8=Q+, Rad1#.
+
+A SAN move only names the piece and the destination square, so it can only be formatted and parsed against the
+position it is played in: the origin square is added (file first, then rank, then both) only when another piece of
+the same type can legally reach the same square, and the check (+) or mate (#) suffix depends on the resulting position.
+*/
+
+// sanPieces are the piece letters used by SAN. Pawns have no letter in SAN but a leading P is tolerated.
+const sanPieces = "PNBRQK"
+
+// LegalMoves returns the legal moves of the side to move.
+func LegalMoves(b *Board) []int {
+	var ml MoveList
+	GenerateAllMoves(b, &ml)
+
+	moves := make([]int, 0, ml.Count)
+	for i := 0; i < ml.Count; i++ {
+		move := ml.Moves[i].Move
+		res, err := b.MakeMove(move)
+		if err != nil || res == FALSE {
+			continue
+		}
+		b.TakeMove()
+
+		moves = append(moves, move)
+	}
+
+	return moves
+}
+
+// sanPiece returns the SAN letter of a piece, with 'P' for pawns.
+func sanPiece(piece int) byte {
+	c := PceChar[piece]
+	if c >= 'a' {
+		c -= 'a' - 'A'
+	}
+
+	return c
+}
+
+// FormatSAN converts a legal move of the side to move to Standard Algebraic Notation, including the check (+)
+// and mate (#) suffixes. The board is left unchanged.
+//
+// Example usage:
+//
+//	move, _ := ParseMove("g1f3", b)
+//	san := FormatSAN(move, b) // "Nf3"
+func FormatSAN(move int, b *Board) string {
+	var sb strings.Builder
+
+	from := GetFrom(move)
+	to := GetToSq(move)
+	piece := b.Pieces[from]
+
+	switch {
+	case move&MoveFlagCastle != 0:
+		if FilesBrd[to] > FilesBrd[from] {
+			sb.WriteString("O-O")
+		} else {
+			sb.WriteString("O-O-O")
+		}
+	case PiecePawn[piece] == TRUE:
+		if move&MoveFlagCapture != 0 || move&MoveFlagEnPassant != 0 {
+			sb.WriteByte(FileChar[FilesBrd[from]])
+			sb.WriteByte('x')
+		}
+		sb.WriteString(sqString(to))
+
+		if promoted := GetPromoted(move); promoted != EMPTY {
+			sb.WriteByte('=')
+			sb.WriteByte(sanPiece(promoted))
+		}
+	default:
+		sb.WriteByte(sanPiece(piece))
+
+		// other pieces of the same type that can legally reach the destination square
+		sameFile, sameRank, others := false, false, false
+		for _, m := range LegalMoves(b) {
+			other := GetFrom(m)
+			if other == from || GetToSq(m) != to || b.Pieces[other] != piece || m&MoveFlagCastle != 0 {
+				continue
+			}
+
+			others = true
+			sameFile = sameFile || FilesBrd[other] == FilesBrd[from]
+			sameRank = sameRank || RanksBrd[other] == RanksBrd[from]
+		}
+
+		if others {
+			if !sameFile {
+				sb.WriteByte(FileChar[FilesBrd[from]])
+			} else if !sameRank {
+				sb.WriteByte(RankChar[RanksBrd[from]])
+			} else {
+				sb.WriteString(sqString(from))
+			}
+		}
+
+		if move&MoveFlagCapture != 0 {
+			sb.WriteByte('x')
+		}
+		sb.WriteString(sqString(to))
+	}
+
+	res, err := b.MakeMove(move)
+	if err != nil || res == FALSE {
+		return sb.String()
+	}
+
+	if SqAttacked(b.KingSq[b.Side], b.Side^1, b) == TRUE {
+		if len(LegalMoves(b)) == 0 {
+			sb.WriteByte('#')
+		} else {
+			sb.WriteByte('+')
+		}
+	}
+	b.TakeMove()
+
+	return sb.String()
+}
+
+// ParseSAN parses a move in Standard Algebraic Notation and resolves it against the legal moves of the board.
+//
+// The parser is tolerant of the variations found in the wild: check, mate and annotation suffixes (+, #, !, ?)
+// are ignored, castling can be written with zeros (0-0), the capture sign and the '=' of promotions are optional,
+// promotion pieces can be lowercase (e8q), "e.p." is ignored and a missing promotion piece means a queen.
+// Moves in coordinate notation (e2e4) are accepted as well.
+//
+// An error is returned if the move is malformed, illegal or ambiguous.
+func ParseSAN(san string, b *Board) (int, error) {
+	s := strings.TrimSuffix(strings.TrimSpace(san), "e.p.")
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, "+#!?")
+
+	switch s {
+	case "O-O", "0-0":
+		if move := FindCastleMove(b, true); move != NOMOVE {
+			return move, nil
+		}
+		return NOMOVE, fmt.Errorf("illegal move %q", san)
+	case "O-O-O", "0-0-0":
+		if move := FindCastleMove(b, false); move != NOMOVE {
+			return move, nil
+		}
+		return NOMOVE, fmt.Errorf("illegal move %q", san)
+	}
+
+	if move, err := ParseMove(s, b); err == nil && move != NOMOVE {
+		return move, nil
+	}
+
+	piece := byte('P')
+	if len(s) > 0 && strings.IndexByte(sanPieces, s[0]) >= 0 {
+		piece = s[0]
+		s = s[1:]
+	}
+
+	promoted := byte(0)
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		if i+2 != len(s) {
+			return NOMOVE, fmt.Errorf("invalid SAN move %q", san)
+		}
+		promoted = upperPiece(s[i+1])
+		s = s[:i]
+	} else if n := len(s); n >= 3 && s[n-2] >= '1' && s[n-2] <= '8' && strings.IndexByte(sanPieces[1:5], upperPiece(s[n-1])) >= 0 {
+		promoted = upperPiece(s[n-1])
+		s = s[:n-1]
+	}
+	if promoted != 0 && (piece != 'P' || strings.IndexByte(sanPieces[1:5], promoted) < 0) {
+		return NOMOVE, fmt.Errorf("invalid SAN move %q", san)
+	}
+
+	s = strings.NewReplacer("x", "", ":", "", "-", "").Replace(s)
+	if len(s) < 2 || len(s) > 4 {
+		return NOMOVE, fmt.Errorf("invalid SAN move %q", san)
+	}
+
+	to, ok := parseSquare(s[len(s)-2:])
+	if !ok {
+		return NOMOVE, fmt.Errorf("invalid SAN move %q", san)
+	}
+
+	fromFile, fromRank := FILE_NONE, RANK_NONE
+	for _, c := range []byte(s[:len(s)-2]) {
+		switch {
+		case c >= 'a' && c <= 'h' && fromFile == FILE_NONE:
+			fromFile = int(c - 'a')
+		case c >= '1' && c <= '8' && fromRank == RANK_NONE:
+			fromRank = int(c - '1')
+		default:
+			return NOMOVE, fmt.Errorf("invalid SAN move %q", san)
+		}
+	}
+
+	found := NOMOVE
+	for _, m := range LegalMoves(b) {
+		from := GetFrom(m)
+		if m&MoveFlagCastle != 0 || GetToSq(m) != to || sanPiece(b.Pieces[from]) != piece {
+			continue
+		}
+		if (fromFile != FILE_NONE && FilesBrd[from] != fromFile) || (fromRank != RANK_NONE && RanksBrd[from] != fromRank) {
+			continue
+		}
+		// pawn captures always name the file they are made from
+		if piece == 'P' && fromFile == FILE_NONE && FilesBrd[from] != FilesBrd[to] {
+			continue
+		}
+
+		if p := GetPromoted(m); p != EMPTY {
+			want := promoted
+			if want == 0 {
+				want = 'Q'
+			}
+			if sanPiece(p) != want {
+				continue
+			}
+		} else if promoted != 0 {
+			continue
+		}
+
+		if found != NOMOVE {
+			return NOMOVE, fmt.Errorf("ambiguous move %q", san)
+		}
+		found = m
+	}
+
+	if found == NOMOVE {
+		return NOMOVE, fmt.Errorf("illegal move %q", san)
+	}
+
+	return found, nil
+}
+
+// upperPiece returns the uppercase letter of a piece, so that promotions like e8q are accepted.
+func upperPiece(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - ('a' - 'A')
+	}
+
+	return c
+}
+
+// sqString returns the name of a square (120-square indexing), e.g. "e4".
+func sqString(sq int) string {
+	return string([]byte{FileChar[FilesBrd[sq]], RankChar[RanksBrd[sq]]})
+}
+
+// parseSquare parses the name of a square, e.g. "e4", into a 120-square index.
+func parseSquare(s string) (int, bool) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return NO_SQ, false
+	}
+
+	return FR2SQ(int(s[0]-'a'), int(s[1]-'1')), true
+}
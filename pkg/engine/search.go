@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"time"
@@ -9,71 +11,399 @@ import (
 
 type SearchInfo struct {
 	StartTime time.Time
-	StopTime  time.Time
+	StopTime  time.Time // Only honoured once the first iteration is over, so that the best move is always searched.
 	Depth     int
 	Depthset  int
 	Timeset   int
 	Movestogo int
-	Infinite  int
+	Infinite  int // Search until stopped, even once the best move is known.
 
-	Nodes   uint64
-	Quit    int
-	Stopped int
+	Ponder        int           // Search on the opponent's time, until ponderhit or stop.
+	ponderTimeset int           // Timeset of the search once the opponent played the expected move.
+	ponderTime    time.Duration // Time for the move, starting at ponderhit.
+
+	NodesLimit  uint64 // Stop after searching about this many nodes, no limit if 0.
+	Mate        int    // Stop once a mate in this many moves is found, 0 to search normally.
+	SearchMoves []int  // Only search these root moves, all of them if empty.
+
+	MoveOverhead int // Milliseconds kept on the clock for every move, for the delays of the GUI and the network.
+
+	MultiPV  int   // Number of best lines to search and report, 1 if not set.
+	excluded []int // First moves of the lines already searched in the current iteration of a MultiPV search.
+
+	Nodes    uint64
+	SelDepth int // Deepest ply reached by the current iteration, quiescence included.
+	Quit     int
+	Stopped  int
+
+	completedDepth int // Depth of the last iteration searched to the end, 0 during the first one.
 
 	FailHigh      float64
 	FailHighFirst float64
 
 	GameMode     int
 	PostThinking int
+
+	Toggles SearchToggles // Parts of the search turned off through the options.
+
+	OwnBook int   // Play moves from Book before searching.
+	Book    *Book // Polyglot opening book, nil if none is loaded.
+
+	SyzygyPath string // Directories of the Syzygy tablebases, kept for when they are probed.
+
+	Threads int           // Number of search threads (Lazy SMP), 1 if not set.
+	smp     *smpSearch    // State shared by the threads of the current search, nil with a single thread.
+	thread  *searchThread // Helper thread this search info belongs to, nil for the main thread.
+
+	Input  *Input    // Commands of the GUI, polled while searching. Nil when the search is not run by a protocol loop.
+	Output io.Writer // Where the protocol loops write to, os.Stdout if nil.
+
+	ctx       context.Context               // Stops the search when done, nil if the search is not run by an Engine.
+	ponderhit chan struct{}                 // Receives the PonderHit calls of an Engine.
+	progress  func(Info)                    // Reports the lines of the search, may be nil.
+	currMove  func(depth, move, number int) // Reports the root move being searched, may be nil.
+
+	// GameOver is called with the board and the result ("1-0", "0-1", "1/2-1/2" or "*") when a game played
+	// in console or xboard mode ends or is abandoned, e.g. to save it to a PGN file.
+	GameOver func(b *Board, result string)
+}
+
+// SearchToggles turn parts of the search off, to measure what they bring in self-play or with cmd/bench.
+// The zero value keeps everything on.
+type SearchToggles struct {
+	NoLateMoveReductions     bool
+	NoLateMovePruning        bool
+	NoReverseFutilityPruning bool
+	NoFutilityPruning        bool
+	NoRazoring               bool
+	NoDeltaPruning           bool
+	NoNullMovePruning        bool
 }
 
 // SearchPosition initiates the chess engine's search from the current position on the given board.
 // It uses the specified SearchInfo struct to guide the search parameters and store search-related information.
 // The function performs an iterative deepening search, updating the principal variation and best move found
-// during the search process.
-func SearchPosition(b *Board, s *SearchInfo) {
-	bestMove := NOMOVE
+// during the search process, and returns the best move once the search is over. Every line of every iteration is
+// reported to s.progress.
+// When OwnBook is set and the position is in the opening book, a book move is returned without searching.
+func SearchPosition(b *Board, s *SearchInfo) Result {
+	if s.OwnBook == TRUE && s.Book != nil {
+		if move := s.Book.PickMove(b); move != NOMOVE {
+			return Result{BestMove: move, PonderMove: NOMOVE, Book: true}
+		}
+	}
+
+	res := Result{BestMove: NOMOVE, PonderMove: NOMOVE}
+
 	ClearForSearch(b, s)
+	startHelpers(b, s)
+
+	lines := multiPVLines(b, s)
+	lastScores := make([]int, lines+1)
 
 	for currentDepth := 1; currentDepth <= s.Depth; currentDepth++ {
-		bestScore := AlphaBeta(-INFINITE, INFINITE, currentDepth, TRUE, b, s)
+		bestScore := -INFINITE
+		s.SelDepth = 0
+
+		// every line of a MultiPV search is searched without the first moves of the lines before it
+		s.excluded = s.excluded[:0]
+		for line := 1; line <= lines; line++ {
+			score := aspirationSearch(b, s, line, currentDepth, lastScores[line])
+
+			if s.Stopped == TRUE {
+				break
+			}
+			lastScores[line] = score
+
+			pvMoves := GetPvLine(currentDepth, b)
+			if line == 1 {
+				bestScore = score
+				res.BestMove = b.PvArray[0]
+				res.PonderMove = NOMOVE
+				if pvMoves > 1 {
+					res.PonderMove = b.PvArray[1]
+				}
+				res.Score = score
+				res.PV = append(res.PV[:0], b.PvArray[:pvMoves]...)
+				res.Depth = currentDepth
+				res.SelDepth = s.SelDepth
+			}
+			s.excluded = append(s.excluded, b.PvArray[0])
+
+			reportSearchLine(b, s, line, currentDepth, score, -INFINITE, INFINITE, pvMoves)
+		}
 
-		if s.Stopped == 1 {
+		if s.Stopped == TRUE {
 			break
 		}
+		s.completedDepth = currentDepth
 
-		pvMoves := GetPvLine(currentDepth, b)
-		bestMove = b.PvArray[0]
+		// go mate n: a mate in n moves or less was found
+		if moves := MateMoves(bestScore); s.Mate > 0 && moves > 0 && moves <= s.Mate {
+			break
+		}
+	}
 
-		elapsed := time.Since(s.StartTime).Milliseconds()
+	s.excluded = nil
+	stopHelpers(s)
 
-		if s.GameMode == UCIMODE {
-			fmt.Printf("info score cp %d depth %d nodes %1d time %d ", bestScore, currentDepth, s.Nodes, elapsed)
-		} else if s.GameMode == XBOARDMODE && s.PostThinking == TRUE {
-			fmt.Printf("%d %d %d %1d ", currentDepth, bestScore, elapsed, s.Nodes)
-		} else if s.PostThinking == TRUE {
-			fmt.Printf("score:%d depth:%d nodes:%1d time:%d(ms) ", bestScore, currentDepth, s.Nodes, elapsed)
+	// stopped before the first iteration was over, any legal move is better than none
+	if res.BestMove == NOMOVE {
+		if moves := LegalMoves(b); len(moves) > 0 {
+			res.BestMove = moves[0]
+			if len(s.SearchMoves) > 0 {
+				res.BestMove = s.SearchMoves[0]
+			}
 		}
+	}
+
+	// the best move of an infinite or ponder search is only returned once it is stopped (or after ponderhit)
+	waitForStop(s)
+
+	if res.PonderMove == NOMOVE {
+		res.PonderMove = hashReply(b, res.BestMove)
+	}
+	res.Nodes = s.Nodes
+	res.Time = time.Since(s.StartTime)
+
+	return res
+}
+
+// Aspiration windows: from aspirationDepth on, the root is searched with a window of aspirationWindow centipawns
+// around the score of the previous iteration, which cuts off more of the tree than a full window. The window is
+// widened on the side where the score falls outside of it, more every time, until the score is inside.
+const (
+	aspirationDepth  = 5
+	aspirationWindow = 50
+)
+
+// aspirationSearch searches a line of the root to the given depth, with an aspiration window around the score
+// of the line at the previous iteration.
+func aspirationSearch(b *Board, s *SearchInfo, line, depth, lastScore int) int {
+	alpha, beta := -INFINITE, INFINITE
+	delta := aspirationWindow
+
+	// the score of a mate does not change by a few centipawns from one iteration to the next
+	if depth >= aspirationDepth && MateMoves(lastScore) == 0 {
+		alpha, beta = lastScore-delta, lastScore+delta
+	}
+
+	for {
+		score := AlphaBeta(alpha, beta, depth, TRUE, b, s)
+		if s.Stopped == TRUE || (score > alpha && score < beta) {
+			return score
+		}
+
+		// only report the bounds of long searches, for GUIs not to flicker between the lines of fast ones
+		if time.Since(s.StartTime) > time.Second {
+			reportSearchLine(b, s, line, depth, score, alpha, beta, GetPvLine(depth, b))
+		}
+
+		delta *= 2
+		if score <= alpha {
+			alpha = score - delta
+			if alpha < -INFINITE {
+				alpha = -INFINITE
+			}
+		} else {
+			beta = score + delta
+			if beta > INFINITE {
+				beta = INFINITE
+			}
+		}
+	}
+}
 
-		if s.GameMode == UCIMODE || s.PostThinking == TRUE {
-			fmt.Printf("pv")
-			for i := 0; i < pvMoves; i++ {
-				fmt.Printf(" %s", PrintMove(b.PvArray[i]))
+// hashReply returns the move stored in the hash table for the position after move, when it is legal, or NOMOVE.
+// It is the move to ponder on when the search was stopped before finding one.
+func hashReply(b *Board, move int) int {
+	if move == NOMOVE {
+		return NOMOVE
+	}
+
+	reply := NOMOVE
+	if res, _ := b.MakeMove(move); res == TRUE {
+		if m := ProbePvMove(b); m != NOMOVE {
+			if ok, _ := MoveExists(b, m); ok == TRUE {
+				reply = m
 			}
-			fmt.Printf("\n")
 		}
+		b.TakeMove()
+	}
+	b.Ply = 0
+
+	return reply
+}
+
+func (s *SearchInfo) out() io.Writer {
+	if s.Output == nil {
+		return os.Stdout
+	}
+
+	return s.Output
+}
+
+// reportSearchLine reports a line of the search (the PV of an iteration, or one of its lines with MultiPV).
+// A score outside of the alpha-beta window of the search is only a bound.
+func reportSearchLine(b *Board, s *SearchInfo, line, depth, score, alpha, beta, pvMoves int) {
+	if s.progress == nil {
+		return
+	}
+
+	bound := HFEXACT
+	if score >= beta {
+		bound = HFBETA
+	} else if score <= alpha {
+		bound = HFALPHA
 	}
 
+	s.progress(Info{
+		Depth:    depth,
+		SelDepth: s.SelDepth,
+		MultiPV:  line,
+		Score:    score,
+		Bound:    bound,
+		Nodes:    s.TotalNodes(),
+		Time:     time.Since(s.StartTime),
+		Hashfull: b.HashTable.Hashfull(),
+		PV:       append([]int(nil), b.PvArray[:pvMoves]...),
+	})
+}
+
+// reportCurrMove reports which root move is being searched. It starts after a second of search,
+// not to flood the GUI in fast searches.
+func reportCurrMove(s *SearchInfo, depth, move, number int) {
+	if s.currMove == nil || s.thread != nil || time.Since(s.StartTime) < time.Second {
+		return
+	}
+
+	s.currMove(depth, move, number)
+}
+
+// printInfo prints a line of the search for the protocol of the current game mode.
+func printInfo(b *Board, s *SearchInfo, info Info) {
+	out := s.out()
+	elapsed := info.Time.Milliseconds()
+	score := info.Score
+
 	if s.GameMode == UCIMODE {
-		fmt.Printf("bestmove %s\n", PrintMove(bestMove))
-	} else if s.GameMode == XBOARDMODE {
-		fmt.Printf("move %s\n", PrintMove(bestMove))
-		b.MakeMove(bestMove)
-	} else {
-		fmt.Printf("\n\n***Alpaca makes move %s***\n\n", PrintMove(bestMove))
-		b.MakeMove(bestMove)
-		b.PrintBoard(os.Stdout)
+		fmt.Fprintf(out, "info depth %d seldepth %d ", info.Depth, info.SelDepth)
+		if s.MultiPV > 1 {
+			fmt.Fprintf(out, "multipv %d ", info.MultiPV)
+		}
+		if moves := MateMoves(score); moves != 0 {
+			fmt.Fprintf(out, "score mate %d ", moves)
+		} else {
+			fmt.Fprintf(out, "score cp %d ", score)
+		}
+		if info.Bound == HFBETA {
+			fmt.Fprintf(out, "lowerbound ")
+		} else if info.Bound == HFALPHA {
+			fmt.Fprintf(out, "upperbound ")
+		}
+		fmt.Fprintf(out, "nodes %d nps %d hashfull %d time %d ", info.Nodes, info.NPS(), info.Hashfull, elapsed)
+	} else if s.GameMode == XBOARDMODE && s.PostThinking == TRUE {
+		// xboard shows mates from scores of 100000 + moves
+		if moves := MateMoves(score); moves > 0 {
+			score = 100000 + moves
+		} else if moves < 0 {
+			score = -100000 + moves
+		}
+		fmt.Fprintf(out, "%d %d %d %1d ", info.Depth, score, elapsed, info.Nodes)
+	} else if s.PostThinking == TRUE {
+		if s.MultiPV > 1 {
+			fmt.Fprintf(out, "%d. ", info.MultiPV)
+		}
+		if moves := MateMoves(score); moves != 0 {
+			fmt.Fprintf(out, "score:mate %d ", moves)
+		} else {
+			fmt.Fprintf(out, "score:%d ", score)
+		}
+		fmt.Fprintf(out, "depth:%d seldepth:%d nodes:%1d time:%d(ms) ", info.Depth, info.SelDepth, info.Nodes, elapsed)
+	}
+
+	if s.GameMode == UCIMODE || s.PostThinking == TRUE {
+		fmt.Fprintf(out, "pv")
+		for _, move := range info.PV {
+			fmt.Fprintf(out, " %s", searchMoveString(move, b, s))
+		}
+		fmt.Fprintf(out, "\n")
+	}
+}
+
+// printBestMove sends the result of a search for the protocol of the current game mode. In xboard and console mode
+// the engine plays the move on the board.
+func printBestMove(b *Board, s *SearchInfo, res Result) {
+	out := s.out()
+
+	switch s.GameMode {
+	case UCIMODE:
+		if res.PonderMove != NOMOVE {
+			fmt.Fprintf(out, "bestmove %s ponder %s\n", searchMoveString(res.BestMove, b, s), searchMoveString(res.PonderMove, b, s))
+		} else {
+			fmt.Fprintf(out, "bestmove %s\n", searchMoveString(res.BestMove, b, s))
+		}
+	case XBOARDMODE:
+		fmt.Fprintf(out, "move %s\n", searchMoveString(res.BestMove, b, s))
+		b.MakeMove(res.BestMove)
+		b.Ply = 0
+	default:
+		fmt.Fprintf(out, "\n\n***Alpaca makes move %s***\n\n", FormatSAN(res.BestMove, b))
+		b.MakeMove(res.BestMove)
+		b.Ply = 0
+		b.PrintBoard(out)
+	}
+}
+
+// MateMoves converts a mate score to the number of moves to mate: n > 0 when the side to move mates in n moves,
+// n < 0 when it is mated in -n moves, and 0 when the score is not a mate score.
+func MateMoves(score int) int {
+	if score > ISMATE {
+		return (INFINITE - score + 1) / 2
+	}
+	if score < -ISMATE {
+		return -(INFINITE + score) / 2
+	}
+
+	return 0
+}
+
+// multiPVLines returns the number of lines to search: MultiPV, but no more than the number of root moves.
+func multiPVLines(b *Board, s *SearchInfo) int {
+	lines := 0
+	for _, move := range LegalMoves(b) {
+		if len(s.SearchMoves) == 0 || containsMove(s.SearchMoves, move) {
+			lines++
+		}
+	}
+
+	if s.MultiPV < lines {
+		lines = s.MultiPV
+	}
+	if lines < 1 {
+		lines = 1
+	}
+
+	return lines
+}
+
+// skipRootMove reports whether a root move is left out of the search, because it is not in searchmoves
+// or because it starts one of the lines already searched in this iteration of a MultiPV search.
+func skipRootMove(s *SearchInfo, move int) bool {
+	if len(s.SearchMoves) > 0 && !containsMove(s.SearchMoves, move) {
+		return true
 	}
+
+	return containsMove(s.excluded, move)
+}
+
+// searchMoveString formats a move for the protocol of the current game mode.
+func searchMoveString(move int, b *Board, s *SearchInfo) string {
+	if s.GameMode == XBOARDMODE {
+		return XBoardMove(move, b.Chess960)
+	}
+
+	return FormatMove(move, b.Chess960)
 }
 
 func ClearForSearch(b *Board, s *SearchInfo) {
@@ -89,14 +419,13 @@ func ClearForSearch(b *Board, s *SearchInfo) {
 		}
 	}
 
-	b.HashTable.OverWrite = 0
-	b.HashTable.Hit = 0
-	b.HashTable.Cut = 0
+	b.HashTable.NewSearch()
 
 	b.Ply = 0
 
 	s.Stopped = 0
 	s.Nodes = 0
+	s.completedDepth = 0
 	s.FailHighFirst = 0
 	s.FailHigh = 0
 }
@@ -121,6 +450,9 @@ func Quiescence(alpha, beta int, b *Board, s *SearchInfo) int {
 	}
 
 	s.Nodes++
+	if b.Ply > s.SelDepth {
+		s.SelDepth = b.Ply
+	}
 
 	if b.IsRepetition() || b.FiftyMove >= 100 {
 		return 0
@@ -130,27 +462,40 @@ func Quiescence(alpha, beta int, b *Board, s *SearchInfo) int {
 		return EvalPosition(b)
 	}
 
-	score := EvalPosition(b)
+	standPat := EvalPosition(b)
 
-	if score >= beta {
+	if standPat >= beta {
 		return beta
 	}
 
-	if score > alpha {
-		alpha = score
+	if standPat > alpha {
+		alpha = standPat
 	}
 
 	var ml MoveList
 	GenerateAllCaptures(b, &ml)
 
 	legal := 0
-	score = -INFINITE
+	score := -INFINITE
 
 	for i := 0; i < ml.Count; i++ {
 
 		PickNextMove(i, &ml)
 
-		res, err := b.MakeMove(ml.Moves[i].Move)
+		move := ml.Moves[i].Move
+
+		// a capture losing material can hardly raise alpha
+		if losingCapture(b, move) {
+			continue
+		}
+
+		// delta pruning: neither can a capture whose victim, and a margin, do not bring the evaluation up to alpha
+		if !s.Toggles.NoDeltaPruning && move&MoveFlagPromotion == 0 && move&MoveFlagEnPassant == 0 &&
+			standPat+PieceVal[GetCaptured(move)]+deltaMargin <= alpha {
+			continue
+		}
+
+		res, err := b.MakeMove(move)
 		if err != nil {
 			panic(err)
 		}
@@ -195,11 +540,28 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 	}
 
 	s.Nodes++
+	if b.Ply > s.SelDepth {
+		s.SelDepth = b.Ply
+	}
 
 	if (b.IsRepetition() || b.FiftyMove >= 100) && b.Ply != 0 {
 		return 0
 	}
 
+	// mate distance pruning: nothing found from here can be better than mating at the next ply,
+	// or worse than being mated now, so the window can be narrowed to these scores
+	if b.Ply > 0 {
+		if alpha < -INFINITE+b.Ply {
+			alpha = -INFINITE + b.Ply
+		}
+		if beta > INFINITE-b.Ply-1 {
+			beta = INFINITE - b.Ply - 1
+		}
+		if alpha >= beta {
+			return alpha
+		}
+	}
+
 	if b.Ply > MAXDEPTH-1 {
 		return EvalPosition(b)
 	}
@@ -212,27 +574,84 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 	score := -INFINITE
 	pvMove := NOMOVE
 
-	if ProbeHashEntry(b, &pvMove, &score, alpha, beta, depth) == TRUE {
-		b.HashTable.Cut++
+	// with searchmoves or MultiPV the entry of the root may come from a search of other moves
+	if ProbeHashEntry(b, &pvMove, &score, alpha, beta, depth) == TRUE && (b.Ply > 0 || (len(s.SearchMoves) == 0 && s.MultiPV <= 1)) {
 		return score
 	}
 
-	if doNull == 1 && inCheck == 0 && b.Ply > 0 && b.BigPCE[b.Side] > 0 && depth >= 4 {
+	// the root entry holds the PV of the main thread, which SearchPosition reads after the search
+	store := b.Ply > 0 || s.thread == nil
+
+	// a node searched with an open window, whose score may become part of the principal variation
+	pvNode := beta-alpha > 1
+
+	// a window at a mate score looks for the shortest mate, whose quiet moves must not be searched less deep
+	mateWindow := alpha <= -ISMATE || alpha >= ISMATE || beta <= -ISMATE || beta >= ISMATE
+
+	// the forward pruning below relies on the static evaluation, meaningless when in check
+	staticEval := -INFINITE
+	if inCheck == FALSE {
+		staticEval = EvalPosition(b)
+	}
+
+	// reverse futility pruning: close to the leaves, a position whose evaluation is well above beta is not
+	// expected to fall below it in the few plies left. The evaluation says nothing about mates, neither here
+	// nor in razoring and futility pruning, which are not done with mate scores in the window
+	if !pvNode && inCheck == FALSE && b.Ply > 0 && !s.Toggles.NoReverseFutilityPruning && depth <= rfpDepth &&
+		beta > -ISMATE && beta < ISMATE && staticEval-rfpMargin*depth >= beta {
+		return beta
+	}
+
+	// razoring: a position whose evaluation is far below alpha is only searched by the quiescence search,
+	// unless the captures bring it back above alpha
+	if !pvNode && inCheck == FALSE && b.Ply > 0 && !s.Toggles.NoRazoring && depth <= razorDepth &&
+		alpha > -ISMATE && alpha < ISMATE && staticEval+razorMargin[depth] <= alpha {
+		score = Quiescence(alpha, beta, b, s)
+		if depth == 1 || score <= alpha {
+			return score
+		}
+	}
+
+	// null move pruning: a position still above beta after passing is expected to be above beta after any move.
+	// It is wrong in zugzwang, so it is only tried with a piece besides the king and, deep in the tree, a cutoff is
+	// only trusted once a search of the moves to the same reduced depth confirms it
+	pieces := b.BigPCE[b.Side] - 1 // BigPCE counts the king
+	if doNull == TRUE && inCheck == FALSE && !pvNode && b.Ply > 0 && !s.Toggles.NoNullMovePruning && pieces > 0 &&
+		depth >= nullDepth && staticEval >= beta {
+		r := nullReduction(depth, staticEval-beta)
+
 		b.MakeNullMove()
-		score = -AlphaBeta(-beta, -beta+1, depth-4, FALSE, b, s)
+		score = -AlphaBeta(-beta, -beta+1, depth-1-r, FALSE, b, s)
 		b.TakeNullMove()
 		if s.Stopped == TRUE {
 			return 0
 		}
 
 		if score >= beta && math.Abs(float64(score)) < ISMATE {
-			return beta
+			if depth < nullVerifyDepth {
+				return beta
+			}
+
+			score = AlphaBeta(beta-1, beta, depth-1-r, FALSE, b, s)
+			if s.Stopped == TRUE {
+				return 0
+			}
+			if score >= beta {
+				return beta
+			}
 		}
 	}
 
 	var ml MoveList
 	GenerateAllMoves(b, &ml)
 
+	// captures losing material are searched after the quiet moves, in MVV-LVA order
+	for i := 0; i < ml.Count; i++ {
+		if losingCapture(b, ml.Moves[i].Move) {
+			ml.Moves[i].Score -= 2000000
+		}
+	}
+
 	legal := 0
 	oldAlpha := alpha
 	bestMove := NOMOVE
@@ -248,11 +667,23 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 		}
 	}
 
+	// futility pruning: close to the leaves, the quiet moves of a position far below alpha cannot raise it above
+	// alpha, only the first one is searched
+	futile := !pvNode && inCheck == FALSE && !s.Toggles.NoFutilityPruning && depth <= futilityDepth &&
+		alpha > -ISMATE && alpha < ISMATE && staticEval+futilityMargin[depth] <= alpha
+
 	for i := 0; i < ml.Count; i++ {
 
 		PickNextMove(i, &ml)
 
-		res, err := b.MakeMove(ml.Moves[i].Move)
+		move := ml.Moves[i].Move
+		if b.Ply == 0 && skipRootMove(s, move) {
+			continue
+		}
+
+		killer := move == b.SearchKillers[0][b.Ply] || move == b.SearchKillers[1][b.Ply]
+
+		res, err := b.MakeMove(move)
 		if err != nil {
 			panic(err)
 		}
@@ -262,7 +693,47 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 
 		legal++
 
-		score = -AlphaBeta(-beta, -alpha, depth-1, TRUE, b, s)
+		if b.Ply == 1 {
+			reportCurrMove(s, depth, move, legal)
+		}
+
+		// quiet moves coming late in the ordering are unlikely to be the best ones, unless they are tactical
+		lateQuiet := legal > 1 && inCheck == FALSE && !killer && move&(MoveFlagCapture|MoveFlagPromotion) == 0 &&
+			SqAttacked(b.KingSq[b.Side], b.Side^1, b) == FALSE
+
+		if lateQuiet && futile && bestScore > -ISMATE {
+			b.TakeMove()
+			continue
+		}
+
+		// late move pruning: close to the leaves, once enough moves were searched, the late quiet ones are skipped
+		if lateQuiet && !pvNode && !mateWindow && !s.Toggles.NoLateMovePruning && depth <= lmpDepth && legal > lateMoveCount(depth) && bestScore > -ISMATE {
+			b.TakeMove()
+			continue
+		}
+
+		// late move reductions: they are searched less deep, and searched again to the full depth if they beat alpha
+		reduction := 0
+		if lateQuiet && !mateWindow && !s.Toggles.NoLateMoveReductions && depth >= lmrDepth && legal > lmrMoves {
+			reduction = lmrReduction(depth, legal)
+			if pvNode && reduction > 0 {
+				reduction--
+			}
+		}
+
+		// principal variation search: the first move is expected to be the best one, the others are only searched
+		// with a null window proving that they are not better, and searched again if one is
+		if legal == 1 {
+			score = -AlphaBeta(-beta, -alpha, depth-1, TRUE, b, s)
+		} else {
+			score = -AlphaBeta(-alpha-1, -alpha, depth-1-reduction, TRUE, b, s)
+			if score > alpha && reduction > 0 {
+				score = -AlphaBeta(-alpha-1, -alpha, depth-1, TRUE, b, s)
+			}
+			if score > alpha && score < beta {
+				score = -AlphaBeta(-beta, -alpha, depth-1, TRUE, b, s)
+			}
+		}
 		b.TakeMove()
 
 		if s.Stopped == TRUE {
@@ -271,7 +742,7 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 
 		if score > bestScore {
 			bestScore = score
-			bestMove = ml.Moves[i].Move
+			bestMove = move
 
 			if score > alpha {
 				if score >= beta {
@@ -280,19 +751,21 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 					}
 					s.FailHigh++
 
-					if ml.Moves[i].Move&MoveFlagCapture == 0 {
+					if move&MoveFlagCapture == 0 {
 						b.SearchKillers[1][b.Ply] = b.SearchKillers[0][b.Ply]
-						b.SearchKillers[0][b.Ply] = ml.Moves[i].Move
+						b.SearchKillers[0][b.Ply] = move
 					}
 
-					StoreHashEntry(b, bestMove, beta, HFBETA, depth)
+					if store {
+						StoreHashEntry(b, bestMove, beta, HFBETA, depth)
+					}
 
 					return beta
 				}
 
 				alpha = score
 
-				if ml.Moves[i].Move&MoveFlagCapture == 0 {
+				if move&MoveFlagCapture == 0 {
 					b.SearchHistory[b.Pieces[GetFrom(bestMove)]][GetToSq(bestMove)] += depth
 				}
 			}
@@ -308,6 +781,10 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 		}
 	}
 
+	if !store {
+		return alpha
+	}
+
 	if alpha != oldAlpha {
 		StoreHashEntry(b, bestMove, bestScore, HFEXACT, depth)
 	} else {
@@ -317,9 +794,88 @@ func AlphaBeta(alpha, beta, depth, doNull int, b *Board, s *SearchInfo) int {
 	return alpha
 }
 
+// Late move reductions start at lmrDepth, from the move after the lmrMoves first ones. The reductions grow with the
+// logarithms of the depth and of the number of the move, as in most engines.
+const (
+	lmrDepth = 3
+	lmrMoves = 3
+)
+
+var lmrReductions [MAXDEPTH][MAXPOSITIONMOVES]int
+
+// InitReductions fills the table of the late move reductions.
+func InitReductions() {
+	for depth := 1; depth < MAXDEPTH; depth++ {
+		for moves := 1; moves < MAXPOSITIONMOVES; moves++ {
+			lmrReductions[depth][moves] = int(0.75 + math.Log(float64(depth))*math.Log(float64(moves))/2.25)
+		}
+	}
+}
+
+// lmrReduction returns the reduction of the legal-th move searched to the given depth, which always leaves a search
+// of at least one ply.
+func lmrReduction(depth, legal int) int {
+	if depth > MAXDEPTH-1 {
+		depth = MAXDEPTH - 1
+	}
+	if legal > MAXPOSITIONMOVES-1 {
+		legal = MAXPOSITIONMOVES - 1
+	}
+
+	r := lmrReductions[depth][legal]
+	if r > depth-2 {
+		r = depth - 2
+	}
+
+	return r
+}
+
+// Reverse futility pruning is done up to rfpDepth, for evaluations rfpMargin per ply above beta. Razoring is done up
+// to razorDepth and futility pruning up to futilityDepth, for evaluations their margin below alpha. Delta pruning skips
+// the captures falling deltaMargin short of alpha.
+const (
+	rfpDepth      = 6
+	rfpMargin     = 85
+	razorDepth    = 2
+	futilityDepth = 3
+	deltaMargin   = 200
+)
+
+var razorMargin = [razorDepth + 1]int{0, 300, 550}
+
+var futilityMargin = [futilityDepth + 1]int{0, 150, 275, 450}
+
+// The null move is tried from nullDepth, and its cutoffs are verified from nullVerifyDepth.
+const (
+	nullDepth       = 3
+	nullVerifyDepth = 10
+)
+
+// nullReduction returns the reduction of the null move search, on top of the move passed: 3 plies, one more every
+// 6 plies of depth and one more every 200 centipawns of the evaluation over beta, up to 3.
+func nullReduction(depth, margin int) int {
+	r := 3 + depth/6
+	if m := margin / 200; m < 3 {
+		r += m
+	} else {
+		r += 3
+	}
+
+	return r
+}
+
+// Late move pruning is done up to lmpDepth, after lateMoveCount moves.
+const lmpDepth = 3
+
+func lateMoveCount(depth int) int {
+	return 3 + depth*depth
+}
+
+// PickNextMove swaps the move with the best score among the moves from moveNum on into moveNum. The scores can be
+// negative, losing captures are scored below every quiet move.
 func PickNextMove(moveNum int, ml *MoveList) {
 	var temp Move
-	bestScore := 0
+	bestScore := ml.Moves[moveNum].Score
 	bestNum := moveNum
 
 	for i := moveNum; i < ml.Count; i++ {
@@ -334,11 +890,37 @@ func PickNextMove(moveNum int, ml *MoveList) {
 	ml.Moves[bestNum] = temp
 }
 
-// Check if time is up, or interrupted from GUI
+// Check if time is up, or interrupted from GUI or by the context of the search
 func CheckUp(s *SearchInfo) {
+	if s.thread != nil {
+		checkHelper(s)
+		return
+	}
+
 	now := time.Now()
-	if s.Timeset == 1 && now.After(s.StopTime) {
+	if s.Timeset == 1 && s.completedDepth > 0 && now.After(s.StopTime) {
+		s.Stopped = TRUE
+	}
+	if s.NodesLimit > 0 && s.TotalNodes() >= s.NodesLimit {
+		s.Stopped = TRUE
+	}
+	if s.ctx != nil && s.ctx.Err() != nil {
 		s.Stopped = TRUE
 	}
+	select {
+	case <-s.ponderhit:
+		ponderHit(s)
+	default:
+	}
 	ReadInput(s)
 }
+
+func containsMove(moves []int, move int) bool {
+	for _, m := range moves {
+		if m == move {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package engine
+
+// SEE returns the static exchange evaluation of a capture: the material won (or lost, if negative) by the side to
+// move when both sides keep capturing on the target square with their least valuable attacker, each side being
+// free to stop once going on would lose material.
+//
+// The attackers are looked up with AttackersOf. Every time a piece captures, the square it comes from is cleared
+// from the occupancy and the sliders are looked up again, which brings in the x-ray attackers lined up behind it
+// (a rook behind a rook, a queen behind a bishop...). The king only captures when the opponent has no attacker
+// left. Pins are not taken into account, and a promotion only counts for the move itself.
+func SEE(b *Board, move int) int {
+	from, to := SQ64[GetFrom(move)], SQ64[GetToSq(move)]
+	bb := &b.Bitboards
+
+	var gain [32]int
+	gain[0] = PieceVal[GetCaptured(move)]
+	onSquare := b.Pieces[GetFrom(move)]
+
+	occ := b.Occupancy[BOTH] &^ SetMask[from]
+	if move&MoveFlagEnPassant != 0 {
+		// the pawn taken en passant is behind the target square
+		gain[0] = PieceVal[WP]
+		if b.Side == WHITE {
+			occ &^= SetMask[to-8]
+		} else {
+			occ &^= SetMask[to+8]
+		}
+	}
+	if promoted := GetPromoted(move); promoted != EMPTY {
+		gain[0] += PieceVal[promoted] - PieceVal[WP]
+		onSquare = promoted
+	}
+
+	attackers := (AttackersOf(to, WHITE, occ, bb) | AttackersOf(to, BLACK, occ, bb)) & occ
+	side := b.Side ^ 1
+	depth := 0
+
+	for depth+1 < len(gain) {
+		sq, piece := leastValuableAttacker(attackers&b.Occupancy[side], side, bb)
+		if piece == EMPTY {
+			break
+		}
+
+		// the king cannot capture a defended piece
+		if PieceKing[piece] == TRUE && attackers&b.Occupancy[side^1] != 0 {
+			break
+		}
+
+		depth++
+		gain[depth] = PieceVal[onSquare] - gain[depth-1]
+
+		occ &^= SetMask[sq]
+		attackers |= BishopAttacks(to, occ)&(bb[WB]|bb[BB]|bb[WQ]|bb[BQ]) | RookAttacks(to, occ)&(bb[WR]|bb[BR]|bb[WQ]|bb[BQ])
+		attackers &= occ
+
+		onSquare = piece
+		side ^= 1
+	}
+
+	// every side only goes on with the exchange if that is better than stopping
+	for ; depth > 0; depth-- {
+		if -gain[depth] < gain[depth-1] {
+			gain[depth-1] = -gain[depth]
+		}
+	}
+
+	return gain[0]
+}
+
+// losingCapture reports whether a move is a capture losing material. A capture of a piece worth at least as much as
+// the capturing one cannot lose any, which saves the exchange evaluation of most captures.
+func losingCapture(b *Board, move int) bool {
+	if move&MoveFlagCapture == 0 || PieceVal[GetCaptured(move)] >= PieceVal[b.Pieces[GetFrom(move)]] {
+		return false
+	}
+
+	return SEE(b, move) < 0
+}
+
+// leastValuableAttacker returns the square (64-square indexing) and the piece of the least valuable of the
+// attackers of the given side, or EMPTY if there are none.
+func leastValuableAttacker(attackers uint64, side int, bb *[13]uint64) (int, int) {
+	for _, piece := range [...]int{SidePawn[side], SideKnight[side], SideBishop[side], SideRook[side], SideQueen[side], SideKing[side]} {
+		if set := attackers & bb[piece]; set != 0 {
+			return PopBit(&set), piece
+		}
+	}
+
+	return NO_SQ, EMPTY
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Lazy SMP is the simplest way to use several threads in an alpha-beta search: every thread searches the same position
+with iterative deepening on its own clone of the board, and they only cooperate through the shared transposition table.
+The helper threads fill the table with entries the main thread finds later, which makes its search faster, and since
+the threads do not search in lockstep (half of the helpers start one depth ahead) they explore different parts of the tree.
+
+Only the main thread reports its progress and chooses the best move. When it is done, the helpers are stopped and
+their node counts are added to the ones of the main thread.
+*/
+
+// MAXTHREADS is the maximum number of search threads.
+const MAXTHREADS = 256
+
+// smpSearch is the state shared by the threads of a Lazy SMP search.
+type smpSearch struct {
+	stop    int32 // Set atomically by the main thread to stop the helpers.
+	helpers []*searchThread
+	wg      sync.WaitGroup
+}
+
+// searchThread is a helper thread of a Lazy SMP search, searching on its own clone of the board.
+type searchThread struct {
+	b     *Board
+	s     SearchInfo
+	nodes uint64 // Nodes searched so far, published atomically for the main thread.
+}
+
+// startHelpers starts s.Threads-1 helper threads searching the position of the board, each on a clone of it
+// sharing its hash table.
+func startHelpers(b *Board, s *SearchInfo) {
+	s.smp = nil
+	if s.Threads <= 1 {
+		return
+	}
+
+	smp := &smpSearch{}
+	for i := 1; i < s.Threads; i++ {
+		t := &searchThread{b: b.Clone()}
+		t.b.HashTable = b.HashTable
+		t.s = SearchInfo{
+			StartTime:   s.StartTime,
+			Depth:       MAXDEPTH,
+			GameMode:    s.GameMode,
+			SearchMoves: s.SearchMoves,
+			Toggles:     s.Toggles,
+			smp:         smp,
+			thread:      t,
+		}
+
+		smp.helpers = append(smp.helpers, t)
+		smp.wg.Add(1)
+		go t.search(1 + i%2)
+	}
+
+	s.smp = smp
+}
+
+// stopHelpers stops the helper threads, waits for them and adds their node counts to the main thread.
+func stopHelpers(s *SearchInfo) {
+	if s.smp == nil {
+		return
+	}
+
+	atomic.StoreInt32(&s.smp.stop, TRUE)
+	s.smp.wg.Wait()
+
+	for _, t := range s.smp.helpers {
+		s.Nodes += t.s.Nodes
+	}
+	s.smp = nil
+}
+
+// search runs iterative deepening from startDepth until the main thread stops the search.
+func (t *searchThread) search(startDepth int) {
+	defer t.s.smp.wg.Done()
+
+	for depth := startDepth; depth <= t.s.Depth; depth++ {
+		AlphaBeta(-INFINITE, INFINITE, depth, TRUE, t.b, &t.s)
+
+		if t.s.Stopped == TRUE {
+			break
+		}
+	}
+}
+
+// checkHelper is the CheckUp of the helper threads: they only stop when the main thread tells them to.
+func checkHelper(s *SearchInfo) {
+	atomic.StoreUint64(&s.thread.nodes, s.Nodes)
+
+	if atomic.LoadInt32(&s.smp.stop) == TRUE {
+		s.Stopped = TRUE
+	}
+}
+
+// TotalNodes returns the number of nodes searched by all the threads of the search so far.
+func (s *SearchInfo) TotalNodes() uint64 {
+	nodes := s.Nodes
+	if s.smp != nil {
+		for _, t := range s.smp.helpers {
+			nodes += atomic.LoadUint64(&t.nodes)
+		}
+	}
+
+	return nodes
+}
@@ -1,9 +1,9 @@
 package engine
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"os"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -13,139 +13,184 @@ const INPUTBUFFER = 400 * 6
 const NAME = "ALPACAv0.1"
 
 // go depth 6 wtime 180000 btime 100000 binc 1000 winc 1000 movetime 1000 movestogo 40
-func (b *Board) ParseGo(line string, s *SearchInfo) error {
-	depth := -1
-	movestogo := 30
-	movetime := -1
-	t := -1
-	inc := 0
-	s.Timeset = FALSE
+// go infinite
+// go nodes 100000
+// go mate 3
+// go searchmoves e2e4 d2d4
+// go ponder wtime 180000 btime 100000
+//
+// ParseGo returns the limits of the search asked for by a go command, the searchmoves being parsed on the board.
+// The clock and increment of the side to move are used. The time is split evenly over movestogo moves,
+// or over 30 moves when the number of moves to the next time control is not known.
+// A ponder search has no time limit until ponderhit, when the time of the move starts.
+func (b *Board) ParseGo(line string) Limits {
+	var l Limits
 
 	parts := strings.Fields(line)
+	value := func(i int) int {
+		if i >= len(parts) {
+			return 0
+		}
+		v, _ := strconv.Atoi(parts[i])
+		return v
+	}
+	duration := func(i int) time.Duration {
+		return time.Duration(value(i)) * time.Millisecond
+	}
+
 	for i := 1; i < len(parts); i++ {
 		switch parts[i] {
 		case "depth":
 			i++
-			depth, _ = strconv.Atoi(parts[i])
+			l.Depth = value(i)
 		case "movetime":
 			i++
-			movetime, _ = strconv.Atoi(parts[i])
+			l.MoveTime = duration(i)
 		case "wtime":
 			i++
-			t, _ = strconv.Atoi(parts[i])
+			l.WTime = duration(i)
 		case "btime":
 			i++
-			t, _ = strconv.Atoi(parts[i])
+			l.BTime = duration(i)
 		case "winc":
 			i++
-			inc, _ = strconv.Atoi(parts[i])
+			l.WInc = duration(i)
 		case "binc":
 			i++
-			inc, _ = strconv.Atoi(parts[i])
+			l.BInc = duration(i)
+		case "movestogo":
+			i++
+			l.MovesToGo = value(i)
+		case "infinite":
+			l.Infinite = true
+		case "ponder":
+			l.Ponder = true
+		case "nodes":
+			i++
+			if n := value(i); n > 0 {
+				l.Nodes = uint64(n)
+			}
+		case "mate":
+			i++
+			l.Mate = value(i)
+		case "searchmoves":
+			for i+1 < len(parts) && !goKeywords[parts[i+1]] {
+				i++
+				if move, _ := ParseMove(parts[i], b); move != NOMOVE {
+					l.SearchMoves = append(l.SearchMoves, move)
+				}
+			}
 		}
 	}
 
-	if movetime != -1 {
-		t = movetime
-		movestogo = 1
-	}
+	return l
+}
 
-	s.StartTime = time.Now()
-	if t != -1 {
-		s.Timeset = TRUE
-		t /= movestogo
-		t -= 50
-		to := time.Millisecond * time.Duration(t+inc)
-		s.StopTime = s.StartTime.Add(to)
-	}
+// goKeywords are the parameters of the go command, ending the list of moves of searchmoves.
+var goKeywords = map[string]bool{
+	"searchmoves": true, "ponder": true, "wtime": true, "btime": true, "winc": true, "binc": true,
+	"movestogo": true, "depth": true, "nodes": true, "mate": true, "movetime": true, "infinite": true,
+}
 
-	if depth == -1 {
-		s.Depth = MAXDEPTH
-	} else {
-		s.Depth = depth
+// position startpos moves e2e4 e7e5
+// position fen rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1 moves e7e5
+//
+// ParsePosition returns an error if the FEN is invalid, in which case the board is left unchanged,
+// or if one of the moves is illegal, in which case the moves before it are kept.
+func (b *Board) ParsePosition(line string) error {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "position"))
+
+	var moves []string
+	if i := strings.Index(line, "moves"); i >= 0 {
+		moves = strings.Fields(line[i+len("moves"):])
+		line = strings.TrimSpace(line[:i])
 	}
 
-	fmt.Printf("time:%d start:%s stop:%s depth:%d timeset:%d\n", t, s.StartTime, s.StopTime, s.Depth, s.Timeset)
-
-	SearchPosition(b, s)
-
-	return nil
+	switch {
+	case line == "startpos":
+		return b.SetPosition(START_FEN, moves)
+	case strings.HasPrefix(line, "fen "):
+		return b.SetPosition(strings.TrimPrefix(line, "fen "), moves)
+	default:
+		return fmt.Errorf("invalid position command %q", line)
+	}
 }
 
-func (b *Board) ParsePosition(line string) {
-	if line[9:17] == "startpos" {
-		b.ParseFen(START_FEN)
-	} else if line[9:12] == "fen" {
-		b.ParseFen(line[13:])
-	} else {
-		b.ParseFen(START_FEN)
+// SetPosition sets the board to a FEN, or "startpos", followed by moves in UCI notation. See ParsePosition for the errors.
+func (b *Board) SetPosition(fen string, moves []string) error {
+	if fen == "startpos" {
+		fen = START_FEN
+	}
+	if err := b.ParseFen(fen); err != nil {
+		return err
 	}
 
-	parts := strings.Split(line, "moves ")
-	if len(parts) > 1 && len(parts[1]) > 0 {
-		moves := strings.Split(parts[1], " ")
-
-		for _, m := range moves {
-			move, _ := ParseMove(m, b)
-			if move == NOMOVE {
-				break
-			}
+	for _, m := range moves {
+		move, _ := ParseMove(m, b)
+		if move == NOMOVE {
+			return fmt.Errorf("illegal move %q", m)
+		}
 
-			b.MakeMove(move)
-			b.Ply = 0
+		// ParseMove only checks the move is generated, a move leaving the king in check is taken back by MakeMove
+		if res, err := b.MakeMove(move); err != nil || res == FALSE {
+			return fmt.Errorf("illegal move %q", m)
 		}
+		b.Ply = 0
 	}
 
-	b.PrintBoard(os.Stdout)
+	return nil
 }
 
+// UCILoop plays with a UCI GUI, reading its commands from s.Input and writing to s.Output (stdin and stdout
+// when they are not set).
 func UCILoop(board *Board, s *SearchInfo) error {
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Printf("id name %s\n", NAME)
-	fmt.Printf("id author Mid\n")
-	fmt.Printf("option name Hash type spin default 64 min 4 max 2048\n")
-	fmt.Printf("uciok\n")
+	input := s.input()
+	out := s.out()
+	s.GameMode = UCIMODE
+	s.MoveOverhead = DefaultMoveOverhead
+
+	e := newEngine(board, s)
+	e.Progress = func(info Info) {
+		printInfo(board, s, info)
+	}
+	e.CurrMove = func(depth, move, number int) {
+		fmt.Fprintf(out, "info depth %d currmove %s currmovenumber %d\n", depth, FormatMove(move, board.Chess960), number)
+	}
 
-	MB := 64
+	printUCIOptions(out)
 
 	for {
-		line, err := reader.ReadString('\n')
+		line, err := input.ReadLine()
 		if err != nil {
 			return err
 		}
 
 		if len(line) >= 7 && line[:7] == "isready" {
-			fmt.Printf("readyok\n")
+			fmt.Fprintf(out, "readyok\n")
 		} else if len(line) >= 8 && line[:8] == "position" {
-			board.ParsePosition(line)
+			if err := board.ParsePosition(line); err != nil {
+				fmt.Fprintf(out, "info string %v\n", err)
+			}
+			board.PrintBoard(out)
 		} else if len(line) >= 10 && line[:10] == "ucinewgame" {
 			board.ParsePosition("position startpos\n")
 		} else if len(line) >= 2 && line[:2] == "go" {
-			board.ParseGo(line, s)
+			res := e.Search(context.Background(), board.ParseGo(line))
+			printBestMove(board, s, res)
 		} else if len(line) >= 4 && line[:4] == "quit" {
 			s.Quit = TRUE
 		} else if len(line) >= 3 && line[:3] == "uci" {
-			fmt.Printf("id name %s\n", NAME)
-			fmt.Printf("id author Mid\n")
-			fmt.Printf("uciok\n")
-		} else if len(line) >= 26 && line[:26] == "setoption name Hash value " {
-			n, err := fmt.Sscanf(line, "setoption name Hash value %d", &MB)
-			if err != nil {
-				panic(err)
-			}
-			if n != 1 {
-				panic("error reading Hash value")
+			printUCIOptions(out)
+		} else if strings.HasPrefix(line, "setoption") {
+			name, value, err := ParseSetOption(line)
+			if err == nil {
+				err = SetOption(board, s, name, value)
 			}
-			if MB < 4 {
-				MB = 4
-			}
-			if MB > 2048 {
-				MB = 2048
+			if err != nil {
+				fmt.Fprintf(out, "info string %v\n", err)
+			} else if value != "" {
+				fmt.Fprintf(out, "info string %s set to %s\n", FindOption(name).Name, value)
 			}
-
-			fmt.Printf("Set Hash to %d MB\n", MB)
-			InitHashTable(board, MB)
 		}
 
 		if s.Quit == TRUE {
@@ -155,3 +200,13 @@ func UCILoop(board *Board, s *SearchInfo) error {
 
 	return nil
 }
+
+// printUCIOptions answers the uci command, advertising the options of the engine.
+func printUCIOptions(out io.Writer) {
+	fmt.Fprintf(out, "id name %s\n", NAME)
+	fmt.Fprintf(out, "id author Mid\n")
+	for _, o := range Options {
+		fmt.Fprintf(out, "%s\n", o.UCI())
+	}
+	fmt.Fprintf(out, "uciok\n")
+}
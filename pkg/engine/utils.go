@@ -2,8 +2,6 @@ package engine
 
 import (
 	"fmt"
-	"os"
-	"strings"
 )
 
 // FR2SQ converts file and rank coordinates to a square index on a 120-square board.
@@ -101,7 +99,7 @@ func InitFilesRanksBrd() {
 //   - b: A pointer to the Board structure representing the current chess position.
 //
 // The following lists and values are updated by this function:
-//   - BigPCE: Count of big pieces (rooks and queens) for each color.
+//   - BigPCE: Count of big pieces (everything but pawns, the king included) for each color.
 //   - MajPCE: Count of major pieces (queens and kings) for each color.
 //   - MinPCE: Count of minor pieces (knights and bishops) for each color.
 //   - Material: Total material value for each color.
@@ -109,6 +107,7 @@ func InitFilesRanksBrd() {
 //   - PCENum: Count of each piece type on the board.
 //   - KingSq: Square positions of the kings for both White and Black.
 //   - Pawns: Bitboards representing pawn locations for both colors.
+//   - Bitboards/Occupancy: Bitboards of every piece type and of the occupied squares.
 //
 // Used to efficiently handles piece type, color, and position tracking
 // to support chess engine operations.
@@ -131,6 +130,10 @@ func UpdateListsMaterial(b *Board) {
 
 			b.Material[color] += PieceVal[piece]
 
+			SetBit(&b.Bitboards[piece], SQ64[sq])
+			SetBit(&b.Occupancy[color], SQ64[sq])
+			SetBit(&b.Occupancy[BOTH], SQ64[sq])
+
 			b.PList[piece][b.PCENum[piece]] = sq
 			b.PCENum[piece]++
 
@@ -197,35 +200,3 @@ func PieceValid(piece int) bool {
 		return false
 	}
 }
-
-func InputWaiting() bool {
-	file := os.Stdin
-	fi, err := file.Stat()
-	if err != nil {
-		fmt.Println("file.Stat()", err)
-	}
-	size := fi.Size()
-	if size > 0 {
-		return true
-	} else {
-		return false
-	}
-}
-
-func ReadInput(s *SearchInfo) {
-	var bytes int
-	input := make([]byte, 256)
-
-	if InputWaiting() {
-		s.Stopped = TRUE
-		for bytes < 0 {
-			bytes, _ = os.Stdin.Read(input)
-		}
-		inputStr := string(input[:bytes])
-		inputStr = strings.TrimSpace(inputStr)
-
-		if len(inputStr) > 0 && inputStr[:4] == "quit" {
-			s.Quit = TRUE
-		}
-	}
-}
@@ -2,17 +2,21 @@ package engine
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
 )
 
 func XBoardLoop(b *Board, s *SearchInfo) error {
-	reader := bufio.NewReader(os.Stdin)
+	input := s.input()
+	out := s.out()
 
 	s.GameMode = XBOARDMODE
 	s.PostThinking = TRUE
+	s.MoveOverhead = DefaultMoveOverhead
 	depth := -1
 	movestogo := [2]int{30, 30}
 	movetime := -1
@@ -24,26 +28,27 @@ func XBoardLoop(b *Board, s *SearchInfo) error {
 	command := ""
 	MB := 64
 	timeLeft := 0
+	recordGame, resetRecorder := gameRecorder(b, s)
+	e := newEngine(b, s)
+	e.Progress = func(info Info) {
+		printInfo(b, s, info)
+	}
 
 	for {
-		if b.Side == engineSide && CheckResult(b) == FALSE {
-			s.StartTime = time.Now()
-			s.Depth = depth
-
+		if b.Side == engineSide && CheckResult(b, out) == FALSE {
+			limits := Limits{Depth: depth, MovesToGo: movestogo[b.Side]}
 			if t != -1 {
-				s.Timeset = TRUE
-				t /= movestogo[b.Side]
-				t -= 50
-				durationOffset := time.Duration(t+inc) * time.Millisecond
-				s.StopTime = s.StartTime.Add(durationOffset)
-			}
-
-			if depth == -1 || depth > MAXDEPTH {
-				s.Depth = MAXDEPTH
+				clock, increment := time.Duration(t)*time.Millisecond, time.Duration(inc)*time.Millisecond
+				if b.Side == WHITE {
+					limits.WTime, limits.WInc = clock, increment
+				} else {
+					limits.BTime, limits.BInc = clock, increment
+				}
 			}
 
-			fmt.Printf("time:%d start:%s stop:%s depth:%d timeset:%d movestgoto:%d mps:%d\n", t, s.StartTime, s.StopTime, s.Depth, s.Timeset, movestogo[b.Side], mps)
-			SearchPosition(b, s)
+			moveTime, _ := limits.TimeForMove(b.Side, s.MoveOverhead)
+			fmt.Fprintf(out, "time:%d depth:%d movestgoto:%d mps:%d\n", moveTime.Milliseconds(), depth, movestogo[b.Side], mps)
+			printBestMove(b, s, e.Search(context.Background(), limits))
 
 			if mps != 0 {
 				movestogo[b.Side^1]--
@@ -53,8 +58,17 @@ func XBoardLoop(b *Board, s *SearchInfo) error {
 			}
 		}
 
-		inBuf, _ = reader.ReadString('\n')
-		inBuf = strings.TrimSpace(inBuf)
+		if s.Quit == TRUE {
+			recordGame("")
+			return nil
+		}
+
+		line, err := input.ReadLine()
+		if err != nil {
+			recordGame("")
+			return err
+		}
+		inBuf = strings.TrimSpace(line)
 
 		if len(inBuf) == 0 {
 			continue
@@ -62,28 +76,41 @@ func XBoardLoop(b *Board, s *SearchInfo) error {
 
 		command = strings.Fields(inBuf)[0]
 
-		fmt.Printf("command seen:%s\n", inBuf)
+		fmt.Fprintf(out, "command seen:%s\n", inBuf)
 
 		switch command {
 		case "quit":
+			recordGame("")
 			s.Quit = TRUE
 			return nil
+		case "result":
+			if fields := strings.Fields(inBuf); len(fields) > 1 {
+				recordGame(fields[1])
+			}
 		case "force":
 			engineSide = BOTH
 		case "protover":
-			PrintOptions()
+			PrintOptions(out)
+		case "option":
+			name, value, err := ParseXBoardOption(inBuf)
+			if err == nil {
+				err = SetOption(b, s, name, value)
+			}
+			if err != nil {
+				fmt.Fprintf(out, "telluser %v\n", err)
+			}
 		case "sd":
 			if n, err := fmt.Sscanf(inBuf, "sd %d", &depth); err == nil && n == 1 {
-				fmt.Printf("DEBUG depth:%d\n", depth)
+				fmt.Fprintf(out, "DEBUG depth:%d\n", depth)
 			}
 		case "st":
 			if n, err := fmt.Sscanf(inBuf, "st %d", &movetime); err == nil && n == 1 {
-				fmt.Printf("DEBUG movetime:%d\n", movetime)
+				fmt.Fprintf(out, "DEBUG movetime:%d\n", movetime)
 			}
 		case "time":
 			if n, err := fmt.Sscanf(inBuf, "time %d", &t); err == nil && n == 1 {
 				t *= 10
-				fmt.Printf("DEBUG time:%d\n", t)
+				fmt.Fprintf(out, "DEBUG time:%d\n", t)
 			}
 		case "memory":
 			n, err := fmt.Sscanf(inBuf, "memory %d", &MB)
@@ -100,16 +127,16 @@ func XBoardLoop(b *Board, s *SearchInfo) error {
 				MB = 2048
 			}
 
-			fmt.Printf("Set Hash to %d MB\n", MB)
+			fmt.Fprintf(out, "Set Hash to %d MB\n", MB)
 			InitHashTable(b, MB)
 		case "level":
 			movetime = -1
 			sec := 0
 			if n, _ := fmt.Sscanf(inBuf, "level %d %d %d", &mps, &timeLeft, &inc); n != 3 {
 				fmt.Sscanf(inBuf, "level %d %d:%d %d", &mps, &timeLeft, &sec, &inc)
-				fmt.Printf("DEBUG level with :\n")
+				fmt.Fprintf(out, "DEBUG level with :\n")
 			} else {
-				fmt.Printf("DEBUG level without :\n")
+				fmt.Fprintf(out, "DEBUG level without :\n")
 			}
 
 			timeLeft *= 60000
@@ -123,67 +150,117 @@ func XBoardLoop(b *Board, s *SearchInfo) error {
 			}
 
 			t = -1
-			fmt.Printf("DEBUG level timeLeft:%d movesToGo:%d inc:%d mps:%d\n", timeLeft, movestogo[0], inc, mps)
+			fmt.Fprintf(out, "DEBUG level timeLeft:%d movesToGo:%d inc:%d mps:%d\n", timeLeft, movestogo[0], inc, mps)
 		case "ping":
-			fmt.Printf("pong%s\n", inBuf[4:])
+			fmt.Fprintf(out, "pong%s\n", inBuf[4:])
 		case "new":
+			recordGame("")
+			resetRecorder()
 			ClearHashTable(b)
 			engineSide = BLACK
+			b.Chess960 = false
 			b.ParseFen(START_FEN)
 			depth = -1
 			t = -1
+		case "variant":
+			// the starting position of a Fischer Random game is sent with setboard afterwards
+			b.Chess960 = strings.TrimSpace(strings.TrimPrefix(inBuf, "variant")) == "fischerandom"
 		case "setboard":
 			engineSide = BOTH
-			b.ParseFen(strings.TrimSpace(strings.TrimPrefix(inBuf, "setboard")))
+			recordGame("")
+			resetRecorder()
+			if err := b.ParseFen(strings.TrimSpace(strings.TrimPrefix(inBuf, "setboard"))); err != nil {
+				fmt.Fprintf(out, "tellusererror Illegal position\n")
+			}
 		case "go":
 			engineSide = b.Side
 		case "usermove":
 			movestogo[b.Side]--
-			move, _ := ParseMove(strings.TrimSpace(strings.TrimPrefix(inBuf, "usermove")), b)
+			move, _ := ParseXBoardMove(strings.TrimSpace(strings.TrimPrefix(inBuf, "usermove")), b)
 			if move != NOMOVE {
 				b.MakeMove(move)
 				b.Ply = 0
+				resetRecorder()
 			}
 		}
 	}
 }
 
-func PrintOptions() {
-	fmt.Println("feature ping=1 setboard=1 colors=0 usermove=1 memory=1")
-	fmt.Println("feature done=1")
+func PrintOptions(out io.Writer) {
+	fmt.Fprintln(out, "feature ping=1 setboard=1 colors=0 usermove=1 memory=1")
+	fmt.Fprintln(out, "feature variants=\"normal,fischerandom\"")
+	for _, o := range Options {
+		if !o.UCIOnly {
+			fmt.Fprintln(out, o.XBoard())
+		}
+	}
+	fmt.Fprintln(out, "feature done=1")
+}
+
+// ParseXBoardMove parses a move sent by an xboard GUI.
+// In Fischer Random games castling is sent as O-O / O-O-O, since the coordinates of the king can be ambiguous.
+func ParseXBoardMove(move string, b *Board) (int, error) {
+	switch move {
+	case "O-O", "0-0":
+		return FindCastleMove(b, true), nil
+	case "O-O-O", "0-0-0":
+		return FindCastleMove(b, false), nil
+	}
+
+	return ParseMove(move, b)
+}
+
+// XBoardMove formats a move for an xboard GUI. Castling is sent as O-O / O-O-O in Fischer Random games.
+func XBoardMove(move int, chess960 bool) string {
+	if chess960 && move&MoveFlagCastle != 0 {
+		if FilesBrd[GetToSq(move)] > FilesBrd[GetFrom(move)] {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+
+	return PrintMove(move)
 }
 
 func ConsoleLoop(b *Board, s *SearchInfo) error {
-	fmt.Println("Alpaca - Console Mode")
-	fmt.Println("Type help for commands")
+	out := s.out()
+	fmt.Fprintln(out, "Alpaca - Console Mode")
+	fmt.Fprintln(out, "Type help for commands")
 
 	s.GameMode = CONSOLEMODE
 	s.PostThinking = TRUE
-	reader := bufio.NewReader(os.Stdin)
+	input := s.input()
 
 	depth := MAXDEPTH
 	movetime := 3000
 	engineSide := BOTH
 	inBuf := ""
 	command := ""
+	recordGame, resetRecorder := gameRecorder(b, s)
+	e := newEngine(b, s)
+	e.Progress = func(info Info) {
+		printInfo(b, s, info)
+	}
 
 	for {
-		if b.Side == engineSide && CheckResult(b) == FALSE {
-			s.StartTime = time.Now()
-			s.Depth = depth
-
-			if movetime != 0 {
-				s.Timeset = TRUE
-				s.StopTime = s.StartTime.Add(time.Duration(movetime) * time.Millisecond)
-			}
+		if b.Side == engineSide && CheckResult(b, out) == FALSE {
+			limits := Limits{Depth: depth, MoveTime: time.Duration(movetime) * time.Millisecond}
+			printBestMove(b, s, e.Search(context.Background(), limits))
+		}
 
-			SearchPosition(b, s)
+		if s.Quit == TRUE {
+			recordGame("")
+			return nil
 		}
 
-		fmt.Print("\nAlpaca > ")
+		fmt.Fprint(out, "\nAlpaca > ")
 
-		inBuf, _ = reader.ReadString('\n')
-		inBuf = strings.TrimSpace(inBuf)
+		line, err := input.ReadLine()
+		if err != nil {
+			recordGame("")
+			return err
+		}
+		inBuf = strings.TrimSpace(line)
 
 		if len(inBuf) == 0 {
 			continue
@@ -193,56 +270,79 @@ func ConsoleLoop(b *Board, s *SearchInfo) error {
 
 		switch command {
 		case "help":
-			fmt.Println("Commands:")
-			fmt.Println("quit - quit game")
-			fmt.Println("force - computer will not think")
-			fmt.Println("print - show board")
-			fmt.Println("post - show thinking")
-			fmt.Println("nopost - do not show thinking")
-			fmt.Println("new - start a new game")
-			fmt.Println("go - set computer thinking")
-			fmt.Println("depth x - set depth to x")
-			fmt.Println("time x - set thinking time to x seconds (depth still applies if set)")
-			fmt.Println("view - show current depth and movetime settings")
-			fmt.Println("setboard x - set position to fen x")
-			fmt.Println("** note ** - to reset time and depth, set to 0")
-			fmt.Println("enter moves using b7b8q notation")
+			fmt.Fprintln(out, "Commands:")
+			fmt.Fprintln(out, "quit - quit game")
+			fmt.Fprintln(out, "force - computer will not think")
+			fmt.Fprintln(out, "print - show board")
+			fmt.Fprintln(out, "post - show thinking")
+			fmt.Fprintln(out, "nopost - do not show thinking")
+			fmt.Fprintln(out, "new - start a new game")
+			fmt.Fprintln(out, "go - set computer thinking")
+			fmt.Fprintln(out, "depth x - set depth to x")
+			fmt.Fprintln(out, "time x - set thinking time to x seconds (depth still applies if set)")
+			fmt.Fprintln(out, "multipv x - show the x best lines when thinking")
+			fmt.Fprintln(out, "view - show current depth and movetime settings")
+			fmt.Fprintln(out, "setboard x - set position to fen x")
+			fmt.Fprintln(out, "fen - show fen of the current position")
+			fmt.Fprintln(out, "ownbook on|off - play moves from the opening book")
+			fmt.Fprintln(out, "bookfile x - load the polyglot opening book x")
+			fmt.Fprintln(out, "** note ** - to reset time and depth, set to 0")
+			fmt.Fprintln(out, "enter moves using b7b8q or SAN (Nf3, exd5, O-O, b8=Q) notation")
 		case "mirror":
 			engineSide = BOTH
-			MirrorEvalTest(b)
+			if err := MirrorEvalTest(b, out); err != nil {
+				fmt.Fprintln(out, err)
+			}
 		case "eval":
-			b.PrintBoard(os.Stdout)
-			fmt.Printf("Eval:%d\n", EvalPosition(b))
+			b.PrintBoard(out)
+			fmt.Fprintf(out, "Eval:%d\n", EvalPosition(b))
 			b.MirrorBoard()
-			b.PrintBoard(os.Stdout)
-			fmt.Printf("Eval:%d\n", EvalPosition(b))
+			b.PrintBoard(out)
+			fmt.Fprintf(out, "Eval:%d\n", EvalPosition(b))
 		case "setboard":
 			engineSide = BOTH
 			fen := strings.TrimSpace(strings.TrimPrefix(inBuf, "setboard"))
-			b.ParseFen(fen)
+			recordGame("")
+			resetRecorder()
+			if err := b.ParseFen(fen); err != nil {
+				fmt.Fprintln(out, err)
+			}
 		case "quit":
+			recordGame("")
 			s.Quit = TRUE
 			return nil
 		case "post":
 			s.PostThinking = TRUE
 		case "print":
-			b.PrintBoard(os.Stdout)
+			b.PrintBoard(out)
 			continue
+		case "fen":
+			fmt.Fprintln(out, b.ToFen())
+			continue
+		case "ownbook":
+			s.OwnBook = FALSE
+			if strings.TrimSpace(strings.TrimPrefix(inBuf, "ownbook")) == "on" {
+				s.OwnBook = TRUE
+			}
+		case "bookfile":
+			if err := s.SetBookFile(strings.TrimSpace(strings.TrimPrefix(inBuf, "bookfile"))); err != nil {
+				fmt.Fprintln(out, err)
+			}
 		case "nopost":
 			s.PostThinking = FALSE
 		case "force":
 			engineSide = BOTH
 		case "view":
 			if depth == MAXDEPTH {
-				fmt.Printf("depth not set ")
+				fmt.Fprintf(out, "depth not set ")
 			} else {
-				fmt.Printf("depth %d", depth)
+				fmt.Fprintf(out, "depth %d", depth)
 			}
 
 			if movetime != 0 {
-				fmt.Printf(" movetime %ds\n", movetime/1000)
+				fmt.Fprintf(out, " movetime %ds\n", movetime/1000)
 			} else {
-				fmt.Println(" movetime not set")
+				fmt.Fprintln(out, " movetime not set")
 			}
 		case "depth":
 			var d int
@@ -259,71 +359,101 @@ func ConsoleLoop(b *Board, s *SearchInfo) error {
 			if err == nil && n == 1 {
 				movetime = t * 1000
 			}
+		case "multipv":
+			var lines int
+			n, err := fmt.Sscanf(inBuf, "multipv %d", &lines)
+			if err == nil && n == 1 && lines >= 1 && lines <= MAXPOSITIONMOVES {
+				s.MultiPV = lines
+			}
 		case "new":
+			recordGame("")
+			resetRecorder()
 			ClearHashTable(b)
 			engineSide = BLACK
 			b.ParseFen(START_FEN)
 		case "go":
 			engineSide = b.Side
 		default:
-			move, err := ParseMove(inBuf, b)
-			if err != nil || move == NOMOVE {
-				fmt.Printf("Command unknown: %s\n", inBuf)
+			move, err := ParseSAN(inBuf, b)
+			if err != nil {
+				fmt.Fprintf(out, "Command unknown: %s (%v)\n", inBuf, err)
 			} else {
 				b.MakeMove(move)
 				b.Ply = 0
+				resetRecorder()
 			}
 		}
 	}
 }
 
-func CheckResult(b *Board) int {
+func CheckResult(b *Board, out io.Writer) int {
+	result, reason := GameResult(b)
+	if result == "*" {
+		return FALSE
+	}
+
+	fmt.Fprintf(out, "%s {%s (claimed by Alpaca)}\n", result, reason)
+	return TRUE
+}
+
+// GameResult returns the result of the game on the board in PGN notation ("1-0", "0-1" or "1/2-1/2") along with
+// the reason for it, or "*" if the game is not over.
+func GameResult(b *Board) (string, string) {
 	if b.FiftyMove > 100 {
-		fmt.Printf("1/2-1/2 {fifty move rule (claimed by Alpaca)}\n")
-		return 1
+		return "1/2-1/2", "fifty move rule"
 	}
 
 	if ThreeFoldRepetition(b) >= 2 {
-		fmt.Printf("1/2-1/2 {3-fold repetition (claimed by Alpaca)}\n")
-		return 1
+		return "1/2-1/2", "3-fold repetition"
 	}
 
 	if DrawMaterial(b) == 1 {
-		fmt.Printf("1/2-1/2 {insufficient material (claimed by Alpaca)}\n")
-		return 1
+		return "1/2-1/2", "insufficient material"
 	}
 
 	var ml MoveList
 	GenerateAllMoves(b, &ml)
-	found := 0
 
 	for i := 0; i < ml.Count; i++ {
 		res, _ := b.MakeMove(ml.Moves[i].Move)
 		if res == 0 {
 			continue
 		}
-		found++
 		b.TakeMove()
-		break
+		return "*", ""
 	}
 
-	if found != 0 {
-		return 0
+	if SqAttacked(b.KingSq[b.Side], b.Side^1, b) == TRUE {
+		if b.Side == WHITE {
+			return "0-1", "black mates"
+		}
+		return "1-0", "white mates"
 	}
 
-	inCheck := SqAttacked(b.KingSq[b.Side], b.Side^1, b)
-	if inCheck == TRUE {
-		if b.Side == WHITE {
-			fmt.Printf("0-1 {black mates (claimed by Alpaca)}\n")
-			return 1
-		} else {
-			fmt.Printf("0-1 {white mates (claimed by Alpaca)}\n")
-			return 1
+	return "1/2-1/2", "stalemate"
+}
+
+// gameRecorder returns a function passing the game played on the board to s.GameOver when it ends or is abandoned.
+// Empty games are skipped and a game is only passed once, until the recorder is reset after a new move or position.
+func gameRecorder(b *Board, s *SearchInfo) (record func(result string), reset func()) {
+	saved := false
+
+	record = func(result string) {
+		if s.GameOver == nil || saved || b.HisPly == 0 {
+			return
 		}
-	} else {
-		fmt.Printf("\n1/2-1/2 {stalemate (claimed by Alpaca)}\n")
-		return 1
+		if result == "" {
+			result, _ = GameResult(b)
+		}
+
+		s.GameOver(b, result)
+		saved = true
 	}
+	reset = func() {
+		saved = false
+	}
+
+	return record, reset
 }
 
 func ThreeFoldRepetition(b *Board) int {
@@ -366,11 +496,10 @@ func DrawMaterial(b *Board) int {
 	return 1
 }
 
-func MirrorEvalTest(b *Board) {
+func MirrorEvalTest(b *Board, out io.Writer) error {
 	file, err := os.Open("./mirror.epd")
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		os.Exit(-1)
+		return err
 	}
 	defer file.Close()
 
@@ -380,7 +509,10 @@ func MirrorEvalTest(b *Board) {
 	for scanner.Scan() {
 		line := scanner.Text()
 		parts := strings.Split(line, ";")
-		b.ParseFen(parts[0])
+		if err := b.ParseFen(parts[0]); err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
 		positions++
 
 		ev1 := EvalPosition(b)
@@ -388,18 +520,20 @@ func MirrorEvalTest(b *Board) {
 		ev2 := EvalPosition(b)
 
 		if ev1 != ev2 {
-			fmt.Printf("\n\n\n")
+			fmt.Fprintf(out, "\n\n\n")
 			b.ParseFen(parts[0])
-			b.PrintBoard(os.Stdout)
+			b.PrintBoard(out)
 			b.MirrorBoard()
-			b.PrintBoard(os.Stdout)
-			fmt.Printf("\n\nMirror Fail:\n%s\n", parts[0])
+			b.PrintBoard(out)
+			fmt.Fprintf(out, "\n\nMirror Fail:\n%s\n", parts[0])
 
-			return
+			return nil
 		}
 
 		if positions%1000 == 0 {
-			fmt.Printf("position %d\n", positions)
+			fmt.Fprintf(out, "position %d\n", positions)
 		}
 	}
+
+	return scanner.Err()
 }
@@ -3,44 +3,130 @@ package perft
 import (
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bbogdan95/alpaca/pkg/engine"
 )
 
-var leafNodes uint64
-
-func Perft(depth int, b *engine.Board) error {
+// Perft returns the number of leaf nodes of the move tree of the position, to the given depth.
+func Perft(depth int, b *engine.Board) (uint64, error) {
 	b.CheckBoard()
 
 	if depth == 0 {
-		leafNodes++
-		return nil
+		return 1, nil
 	}
 
+	leafNodes := uint64(0)
 	ml := &engine.MoveList{}
 	engine.GenerateAllMoves(b, ml)
 
 	for i := 0; i < ml.Count; i++ {
 		res, err := b.MakeMove(ml.Moves[i].Move)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		if res == 0 {
 			continue
 		}
 
-		err = Perft(depth-1, b)
+		nodes, err := Perft(depth-1, b)
 		if err != nil {
-			return err
+			return 0, err
 		}
+		leafNodes += nodes
 		b.TakeMove()
 	}
 
-	return nil
+	return leafNodes, nil
+}
+
+// PerftCopyMake is Perft with copy-make: every move is taken back by restoring the position saved before it,
+// instead of TakeMove.
+func PerftCopyMake(depth int, b *engine.Board) (uint64, error) {
+	b.CheckBoard()
+
+	if depth == 0 {
+		return 1, nil
+	}
+
+	leafNodes := uint64(0)
+	ml := &engine.MoveList{}
+	engine.GenerateAllMoves(b, ml)
+
+	saved := b.Save()
+	for i := 0; i < ml.Count; i++ {
+		res, err := b.MakeMove(ml.Moves[i].Move)
+		if err != nil {
+			return 0, err
+		}
+
+		if res == 0 {
+			continue
+		}
+
+		nodes, err := PerftCopyMake(depth-1, b)
+		if err != nil {
+			return 0, err
+		}
+		leafNodes += nodes
+		b.Restore(saved)
+	}
+
+	return leafNodes, nil
+}
+
+// PerftParallel is Perft with the moves of the position shared out between workers goroutines,
+// each on its own clone of the board.
+func PerftParallel(depth int, b *engine.Board, workers int) (uint64, error) {
+	if depth == 0 {
+		return 1, nil
+	}
+
+	moves := make(chan int)
+	var (
+		wg        sync.WaitGroup
+		leafNodes uint64
+		errOnce   sync.Once
+		firstErr  error
+	)
+
+	for i := 0; i < workers; i++ {
+		c := b.Clone()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for move := range moves {
+				if _, err := c.MakeMove(move); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					continue
+				}
+
+				nodes, err := Perft(depth-1, c)
+				if err != nil {
+					errOnce.Do(func() { firstErr = err })
+					continue
+				}
+				atomic.AddUint64(&leafNodes, nodes)
+				c.TakeMove()
+			}
+		}()
+	}
+
+	for _, move := range engine.LegalMoves(b) {
+		moves <- move
+	}
+	close(moves)
+	wg.Wait()
+
+	return leafNodes, firstErr
 }
 
+// PerftTest runs Perft for every move of the position, printing the number of leaf nodes of each when log is set,
+// and returns the total.
 func PerftTest(depth int, b *engine.Board, log bool) (uint64, error) {
 	b.CheckBoard()
 
@@ -50,7 +136,7 @@ func PerftTest(depth int, b *engine.Board, log bool) (uint64, error) {
 	}
 	start := time.Now()
 
-	leafNodes = 0
+	leafNodes := uint64(0)
 	ml := &engine.MoveList{}
 	engine.GenerateAllMoves(b, ml)
 
@@ -64,14 +150,13 @@ func PerftTest(depth int, b *engine.Board, log bool) (uint64, error) {
 			continue
 		}
 
-		cumnodes := leafNodes
-		err = Perft(depth-1, b)
+		oldnodes, err := Perft(depth-1, b)
 		if err != nil {
 			return 0, err
 		}
+		leafNodes += oldnodes
 
 		b.TakeMove()
-		oldnodes := leafNodes - cumnodes
 
 		if log {
 			fmt.Fprintf(os.Stdout, "move %d : %s : %1d\n", i+1, engine.PrintMove(ml.Moves[i].Move), oldnodes)
@@ -0,0 +1,141 @@
+// Package pgn reads and writes chess games in Portable Game Notation (PGN).
+//
+// A PGN file holds any number of games. Every game starts with tag pairs ([Event "..."]) followed by the movetext:
+// moves in Standard Algebraic Notation with move numbers, {comments} or ; comments, numeric annotation glyphs ($1 or !?),
+// (variations) that can be nested, and the result of the game (1-0, 0-1, 1/2-1/2 or *).
+//
+// The moves are resolved against an engine.Board while reading, so every Move holds the encoded engine move and games
+// can be replayed on a Board. The engine tables must be initialized with engine.InitAll before using this package.
+package pgn
+
+import (
+	"strings"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+// Tag is a PGN tag pair, e.g. [Event "Casual game"].
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Move is a move of a game, along with its annotations and the alternatives to it.
+type Move struct {
+	Move          int      // Encoded engine move.
+	SAN           string   // Move in Standard Algebraic Notation.
+	NAGs          []int    // Numeric annotation glyphs, e.g. 1 for "!" and 2 for "?".
+	CommentBefore string   // Comment placed before the move, only used at the start of a game or variation.
+	Comment       string   // Comment following the move.
+	Variations    [][]Move // Alternatives to this move, each played from the position before it.
+}
+
+// Game is a chess game with its tags, main line and result.
+type Game struct {
+	Tags   []Tag
+	Moves  []Move // Main line of the game.
+	Result string // "1-0", "0-1", "1/2-1/2" or "*".
+}
+
+// sevenTagRoster are the tags every PGN game must have, in the order they are exported.
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// Tag returns the value of the tag with the given name, or "" if the game does not have it.
+func (g *Game) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+
+	return ""
+}
+
+// SetTag sets the value of a tag, adding it if the game does not have it yet.
+func (g *Game) SetTag(name, value string) {
+	for i := range g.Tags {
+		if g.Tags[i].Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+
+	g.Tags = append(g.Tags, Tag{Name: name, Value: value})
+}
+
+// Board returns a new board set up with the starting position of the game, taken from the FEN tag if present.
+// Chess960 is enabled when the Variant tag names it (Chess960, Fischerandom).
+//
+// Example usage:
+//
+//	b, err := g.Board()
+//	if err != nil {
+//		return err
+//	}
+//	for _, m := range g.Moves {
+//		b.MakeMove(m.Move)
+//	}
+func (g *Game) Board() (*engine.Board, error) {
+	variant := strings.ToLower(g.Tag("Variant"))
+	b := &engine.Board{Chess960: strings.Contains(variant, "960") || strings.Contains(variant, "fischer")}
+
+	fen := g.Tag("FEN")
+	if fen == "" {
+		fen = engine.START_FEN
+	}
+	if err := b.ParseFen(fen); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// FromBoard returns the game played on the board, from the position it was set up with up to the current position.
+// The SetUp and FEN tags are added if the game did not start from the standard starting position, and the result is
+// taken from the position. The board is left unchanged.
+func FromBoard(b *engine.Board) *Game {
+	moves := make([]int, b.HisPly)
+	for i := range moves {
+		moves[i] = b.History[i].Move
+	}
+
+	for range moves {
+		takeBack(b)
+	}
+
+	g := &Game{}
+	for _, name := range sevenTagRoster {
+		g.SetTag(name, "?")
+	}
+	g.SetTag("Date", "????.??.??")
+	if fen := b.ToFen(); fen != engine.START_FEN {
+		g.SetTag("SetUp", "1")
+		g.SetTag("FEN", fen)
+	}
+	if b.Chess960 {
+		g.SetTag("Variant", "Chess960")
+	}
+
+	for _, move := range moves {
+		g.Moves = append(g.Moves, Move{Move: move, SAN: engine.FormatSAN(move, b)})
+		play(b, move)
+	}
+
+	g.Result, _ = engine.GameResult(b)
+	g.SetTag("Result", g.Result)
+
+	return g
+}
+
+// play makes a move outside of a search. The search ply is kept at 0 like the protocol loops do, since the engine uses
+// it to index its search tables.
+func play(b *engine.Board, move int) {
+	b.MakeMove(move)
+	b.Ply = 0
+}
+
+// takeBack takes back the last move outside of a search, keeping the search ply at 0.
+func takeBack(b *engine.Board) {
+	b.TakeMove()
+	b.Ply = 0
+}
@@ -0,0 +1,365 @@
+package pgn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenTag
+	tokenSymbol
+	tokenComment
+	tokenNAG
+	tokenOpen
+	tokenClose
+	tokenResult
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	tag   Tag
+	line  int
+}
+
+// symbolNAGs maps the annotation symbols to their numeric annotation glyphs. Only the first six can be used as
+// move suffixes (Nf3!?), the evaluation symbols are found standalone in files exported by some programs.
+var symbolNAGs = map[string]int{
+	"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6,
+	"=": 10, "+=": 14, "=+": 15, "+/-": 16, "-/+": 17, "+-": 18, "-+": 19,
+}
+
+// Reader reads games from a PGN file.
+type Reader struct {
+	r      *bufio.Reader
+	line   int
+	peeked *token
+	games  int
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), line: 1}
+}
+
+// Read reads the next game. It returns io.EOF when there are no more games.
+//
+// A game that does not end with a result (as is common in hand-written files) ends at the next tag pair or at the
+// end of the input, and gets the result "*". Errors report the line and the number of the game they were found in.
+func (r *Reader) Read() (*Game, error) {
+	g := &Game{Result: "*"}
+
+	tok, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok.typ == tokenEOF {
+		return nil, io.EOF
+	}
+	r.games++
+
+	for ; tok.typ == tokenTag; tok, err = r.next() {
+		g.SetTag(tok.tag.Name, tok.tag.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.peeked = &tok
+
+	b, err := g.Board()
+	if err != nil {
+		return nil, r.errorf(tok.line, "%v", err)
+	}
+
+	if g.Moves, err = r.readLine(b, g, 0); err != nil {
+		return nil, err
+	}
+
+	if tag := g.Tag("Result"); g.Result == "*" && tag != "" {
+		g.Result = tag
+	}
+	g.SetTag("Result", g.Result)
+
+	return g, nil
+}
+
+// ReadAll reads all the remaining games.
+func (r *Reader) ReadAll() ([]*Game, error) {
+	var games []*Game
+
+	for {
+		g, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return games, nil
+		}
+		if err != nil {
+			return games, err
+		}
+
+		games = append(games, g)
+	}
+}
+
+// readLine reads the moves of the main line (depth 0) or of a variation, playing them on the board.
+// The moves are taken back before returning, so the board is left in the position it was given in.
+func (r *Reader) readLine(b *engine.Board, g *Game, depth int) ([]Move, error) {
+	var moves []Move
+	comment := ""
+
+	defer func() {
+		for range moves {
+			takeBack(b)
+		}
+	}()
+
+	for {
+		tok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.typ {
+		case tokenSymbol:
+			move, err := engine.ParseSAN(tok.value, b)
+			if err != nil {
+				return nil, r.errorf(tok.line, "%v", err)
+			}
+
+			m := Move{Move: move, SAN: engine.FormatSAN(move, b), CommentBefore: comment}
+			comment = ""
+
+			// annotations written as a move suffix, e.g. Nf3!?
+			san := strings.TrimRight(tok.value, "+#")
+			if i := strings.IndexAny(san, "!?"); i >= 0 {
+				if nag, ok := symbolNAGs[san[i:]]; ok {
+					m.NAGs = append(m.NAGs, nag)
+				}
+			}
+
+			play(b, move)
+			moves = append(moves, m)
+		case tokenNAG:
+			if len(moves) == 0 {
+				return nil, r.errorf(tok.line, "annotation %s before any move", tok.value)
+			}
+
+			nag, ok := symbolNAGs[tok.value]
+			if !ok {
+				nag, err = strconv.Atoi(strings.TrimPrefix(tok.value, "$"))
+				if err != nil || !strings.HasPrefix(tok.value, "$") {
+					return nil, r.errorf(tok.line, "invalid annotation %q", tok.value)
+				}
+			}
+			last := &moves[len(moves)-1]
+			last.NAGs = append(last.NAGs, nag)
+		case tokenComment:
+			if len(moves) == 0 {
+				comment = joinComment(comment, tok.value)
+			} else {
+				last := &moves[len(moves)-1]
+				last.Comment = joinComment(last.Comment, tok.value)
+			}
+		case tokenOpen:
+			if len(moves) == 0 {
+				return nil, r.errorf(tok.line, "variation before any move")
+			}
+
+			// a variation replaces the last move, so it is read from the position before it
+			last := &moves[len(moves)-1]
+			takeBack(b)
+			variation, err := r.readLine(b, g, depth+1)
+			play(b, last.Move)
+			if err != nil {
+				return nil, err
+			}
+
+			last.Variations = append(last.Variations, variation)
+		case tokenClose:
+			if depth == 0 {
+				return nil, r.errorf(tok.line, "unexpected )")
+			}
+			return moves, nil
+		case tokenResult:
+			if depth != 0 {
+				return nil, r.errorf(tok.line, "result %s inside a variation", tok.value)
+			}
+			g.Result = tok.value
+			return moves, nil
+		case tokenTag, tokenEOF:
+			if depth != 0 {
+				return nil, r.errorf(tok.line, "unterminated variation")
+			}
+			r.peeked = &tok
+			return moves, nil
+		}
+	}
+}
+
+func joinComment(a, b string) string {
+	if a == "" {
+		return b
+	}
+
+	return a + " " + b
+}
+
+func (r *Reader) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("pgn: game %d, line %d: %s", r.games, line, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token, skipping move numbers and escaped lines.
+func (r *Reader) next() (token, error) {
+	if r.peeked != nil {
+		tok := *r.peeked
+		r.peeked = nil
+		return tok, nil
+	}
+
+	atLineStart := r.line == 1
+
+	for {
+		c, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			return token{typ: tokenEOF, line: r.line}, nil
+		}
+		if err != nil {
+			return token{}, err
+		}
+
+		switch {
+		case c == '\n':
+			r.line++
+			atLineStart = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\uFEFF':
+			continue
+		case c == '%' && atLineStart, c == ';':
+			text, err := r.r.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return token{}, err
+			}
+			if text != "" && text[len(text)-1] == '\n' {
+				r.line++
+			}
+			atLineStart = true
+			if c == ';' {
+				return token{typ: tokenComment, value: strings.TrimSpace(text), line: r.line - 1}, nil
+			}
+			continue
+		}
+		atLineStart = false
+		line := r.line
+
+		switch c {
+		case '[':
+			tag, err := r.readTag()
+			if err != nil {
+				return token{}, err
+			}
+			return token{typ: tokenTag, tag: tag, line: line}, nil
+		case '{':
+			text, err := r.r.ReadString('}')
+			if err != nil {
+				return token{}, r.errorf(line, "unterminated comment")
+			}
+			r.line += strings.Count(text, "\n")
+			return token{typ: tokenComment, value: strings.Join(strings.Fields(text[:len(text)-1]), " "), line: line}, nil
+		case '(':
+			return token{typ: tokenOpen, line: line}, nil
+		case ')':
+			return token{typ: tokenClose, line: line}, nil
+		case '*':
+			return token{typ: tokenResult, value: "*", line: line}, nil
+		}
+
+		r.r.UnreadRune()
+		word, err := r.readWord()
+		if err != nil {
+			return token{}, err
+		}
+
+		switch {
+		case word == "1-0" || word == "0-1" || word == "1/2-1/2":
+			return token{typ: tokenResult, value: word, line: line}, nil
+		case word[0] == '$' || symbolNAGs[word] != 0:
+			return token{typ: tokenNAG, value: word, line: line}, nil
+		case word[0] >= '0' && word[0] <= '9' && strings.Trim(word, "0123456789.") == "":
+			// move number, e.g. 12. or 12...
+			continue
+		case word[0] >= '0' && word[0] <= '9' && !strings.HasPrefix(word, "0-0"):
+			// move number glued to the move, e.g. 12.e4
+			word = strings.TrimLeft(word, "0123456789.")
+			if word == "" {
+				continue
+			}
+		case strings.Trim(word, ".") == "":
+			continue
+		}
+
+		return token{typ: tokenSymbol, value: word, line: line}, nil
+	}
+}
+
+// readWord reads a symbol up to the next whitespace or delimiter.
+func (r *Reader) readWord() (string, error) {
+	var sb strings.Builder
+
+	for {
+		c, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if strings.ContainsRune(" \t\r\n[]{}();", c) {
+			r.r.UnreadRune()
+			break
+		}
+
+		sb.WriteRune(c)
+	}
+
+	return sb.String(), nil
+}
+
+// readTag reads the rest of a tag pair, after the opening bracket.
+func (r *Reader) readTag() (Tag, error) {
+	line := r.line
+
+	text, err := r.r.ReadString(']')
+	if err != nil {
+		return Tag{}, r.errorf(line, "unterminated tag")
+	}
+
+	// a ] inside the value is part of it
+	for (strings.Count(text, "\"")-strings.Count(text, "\\\""))%2 == 1 {
+		more, err := r.r.ReadString(']')
+		if err != nil {
+			return Tag{}, r.errorf(line, "unterminated tag")
+		}
+		text += more
+	}
+
+	text = strings.TrimSpace(text[:len(text)-1])
+	name, value, _ := strings.Cut(text, " ")
+	value = strings.TrimSpace(value)
+
+	if name == "" || len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return Tag{}, r.errorf(line, "invalid tag [%s]", text)
+	}
+
+	value = strings.NewReplacer("\\\"", "\"", "\\\\", "\\").Replace(value[1 : len(value)-1])
+
+	return Tag{Name: name, Value: value}, nil
+}
@@ -0,0 +1,179 @@
+package pgn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bbogdan95/alpaca/pkg/engine"
+)
+
+// Writer writes games in PGN export format: the seven tag roster first, movetext lines of at most LineLength
+// characters and a blank line after every game.
+type Writer struct {
+	w          *bufio.Writer
+	LineLength int
+}
+
+// NewWriter returns a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), LineLength: 80}
+}
+
+// Write writes a game. The moves are written with their SAN, so games built by hand must fill it in,
+// e.g. with engine.FormatSAN.
+func (w *Writer) Write(g *Game) error {
+	result := g.Result
+	if result == "" {
+		result = "*"
+	}
+
+	for _, name := range sevenTagRoster {
+		value := g.Tag(name)
+		switch {
+		case name == "Result":
+			value = result
+		case value == "" && name == "Date":
+			value = "????.??.??"
+		case value == "":
+			value = "?"
+		}
+		w.writeTag(name, value)
+	}
+	for _, t := range g.Tags {
+		if !isSevenTagRoster(t.Name) {
+			w.writeTag(t.Name, t.Value)
+		}
+	}
+	w.w.WriteString("\n")
+
+	// move numbers depend on the starting position of the game
+	side, moveNumber := engine.WHITE, 1
+	if fen := strings.Fields(g.Tag("FEN")); len(fen) >= 2 {
+		if fen[1] == "b" {
+			side = engine.BLACK
+		}
+		if len(fen) >= 6 {
+			if n, err := strconv.Atoi(fen[5]); err == nil && n > 0 {
+				moveNumber = n
+			}
+		}
+	}
+
+	tokens := movetext(nil, g.Moves, side, moveNumber)
+	tokens = append(tokens, result)
+	w.writeWrapped(tokens)
+	w.w.WriteString("\n")
+
+	return w.w.Flush()
+}
+
+// WriteAll writes several games.
+func (w *Writer) WriteAll(games []*Game) error {
+	for _, g := range games {
+		if err := w.Write(g); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeTag(name, value string) {
+	value = strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(value)
+	fmt.Fprintf(w.w, "[%s \"%s\"]\n", name, value)
+}
+
+// writeWrapped writes the tokens separated by spaces, starting a new line before a token that does not fit.
+// Parentheses are written next to the variations they enclose.
+func (w *Writer) writeWrapped(tokens []string) {
+	length := 0
+
+	for i, tok := range tokens {
+		space := i > 0 && tokens[i-1] != "(" && tok != ")"
+		if length > 0 && length+1+len(tok) > w.LineLength {
+			w.w.WriteString("\n")
+			length = 0
+		}
+		if length > 0 && space {
+			w.w.WriteString(" ")
+			length++
+		}
+
+		w.w.WriteString(tok)
+		length += len(tok)
+	}
+
+	w.w.WriteString("\n")
+}
+
+// movetext appends the tokens of a line of moves played by side from the given move number.
+// Black moves get their move number (12...) at the start of a line and after comments and variations.
+func movetext(tokens []string, moves []Move, side int, moveNumber int) []string {
+	needNumber := true
+
+	for _, m := range moves {
+		if m.CommentBefore != "" {
+			tokens = append(tokens, comment(m.CommentBefore)...)
+			needNumber = true
+		}
+
+		// the move number is kept on the same line as the move
+		if side == engine.WHITE {
+			tokens = append(tokens, fmt.Sprintf("%d. %s", moveNumber, m.SAN))
+		} else if needNumber {
+			tokens = append(tokens, fmt.Sprintf("%d... %s", moveNumber, m.SAN))
+		} else {
+			tokens = append(tokens, m.SAN)
+		}
+		needNumber = false
+
+		for _, nag := range m.NAGs {
+			tokens = append(tokens, fmt.Sprintf("$%d", nag))
+		}
+		if m.Comment != "" {
+			tokens = append(tokens, comment(m.Comment)...)
+			needNumber = true
+		}
+
+		for _, variation := range m.Variations {
+			tokens = append(tokens, "(")
+			tokens = movetext(tokens, variation, side, moveNumber)
+			tokens = append(tokens, ")")
+			needNumber = true
+		}
+
+		if side == engine.BLACK {
+			moveNumber++
+		}
+		side ^= 1
+	}
+
+	return tokens
+}
+
+// comment returns the words of a comment, so that long comments can be wrapped.
+// The closing braces a comment can not contain are removed.
+func comment(text string) []string {
+	words := strings.Fields(strings.ReplaceAll(text, "}", ""))
+	if len(words) == 0 {
+		return []string{"{}"}
+	}
+
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+
+	return words
+}
+
+func isSevenTagRoster(name string) bool {
+	for _, n := range sevenTagRoster {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}